@@ -17,7 +17,11 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -38,17 +42,49 @@ type DaskSpec struct {
 	// +optional
 	Daemon bool `json:"daemon,omitempty"`
 
-	// +kubebuilder:validation:Default=false
+	// Disable Network Policies - default: as set by the DaskClusterClass,
+	// which false turns back on
+	// +optional
+	DisablePolicies *bool `json:"disablepolicies,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
 
-	// Disable Network Policies
+	// Number of workers to spawn - default will be 5, or 0 with workerGroups
 	// +optional
-	DisablePolicies bool `json:"disablepolicies,omitempty"`
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Scale the workers on the load reported by the Dask Scheduler - replaces replicas
+	// +optional
+	Adaptive *DaskAdaptiveSpec `json:"adaptive,omitempty"`
+
+	// Suspend the cluster - scale the Scheduler, workers and Notebook to
+	// zero, keeping everything else in place until it is resumed
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Hibernate the cluster - scale the workers to zero - once the Scheduler
+	// has had no tasks and no clients for this long eg: 2h
+	// +optional
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+
+	// Stop the Jupyter Notebook too while the cluster is hibernated
+	// +optional
+	HibernateNotebook bool `json:"hibernateNotebook,omitempty"`
 
 	// +kubebuilder:validation:Minimum=0
 
-	// Number of workers to spawn - default will be 5
+	// Delete the Dask this many seconds after it was created
+	// +optional
+	TTLSecondsAfterCreation *int64 `json:"ttlSecondsAfterCreation,omitempty"`
+
+	// Delete the Dask at this time - the earlier of this and
+	// ttlSecondsAfterCreation applies
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Lead times before expiry at which Warning events are emitted - default: 1h and 10m
 	// +optional
-	Replicas int32 `json:"replicas,omitempty"`
+	ExpiryWarnings []metav1.Duration `json:"expiryWarnings,omitempty"`
 
 	// +kubebuilder:validation:MinLength=0
 	// +kubebuilder:validation:Default=daskdev/dask:latest
@@ -56,6 +92,18 @@ type DaskSpec struct {
 	// Source image to deploy cluster from - default: daskdev/dask:latest
 	Image string `json:"image,omitempty"`
 
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+){0,2}$`
+
+	// The Dask release in the image eg: 2.9.0, which the Scheduler and
+	// worker command lines are chosen for - default: found when they start
+	// +optional
+	DaskVersion string `json:"daskVersion,omitempty"`
+
+	// The DaskClusterClass that the defaults are taken from - default: the
+	// class annotated analytics.piersharding.com/is-default-class, if any
+	// +optional
+	ClassName string `json:"className,omitempty"`
+
 	// Pull Policy for image - default: IfNotPresent
 	// +optional
 	ImagePullPolicy string `json:"imagePullPolicy,omitempty"`
@@ -64,10 +112,17 @@ type DaskSpec struct {
 	// +optional
 	JupyterIngress string `json:"jupyterIngress,omitempty"`
 
-	// Jupyter Password hostname - eg: password
+	// Jupyter Password - deprecated, as it is plaintext in the Dask, and to
+	// be removed in the next API version: use jupyterAuth instead.  It only
+	// seeds a generated Secret that has no password yet - from then on the
+	// Secret holds the password.
 	// +optional
 	JupyterPassword string `json:"jupyterPassword,omitempty"`
 
+	// How users log in to the Jupyter Notebook - default: a generated password
+	// +optional
+	JupyterAuth *DaskJupyterAuthSpec `json:"jupyterAuth,omitempty"`
+
 	// Scheduler Ingress hostname - eg: scheduler.local.net
 	// +optional
 	SchedulerIngress string `json:"schedulerIngress,omitempty"`
@@ -76,6 +131,16 @@ type DaskSpec struct {
 	// +optional
 	MonitorIngress string `json:"monitorIngress,omitempty"`
 
+	// The class, annotations and TLS of the Ingress for the above hostnames
+	// +optional
+	Ingress *DaskIngressSpec `json:"ingress,omitempty"`
+
+	// Expose the Jupyter Notebook and the Scheduler dashboard with Gateway API
+	// HTTPRoutes, instead of an Ingress - the hostnames are taken from
+	// jupyterIngress and monitorIngress
+	// +optional
+	Gateway *DaskGatewaySpec `json:"gateway,omitempty"`
+
 	// Specifies the Volumes.
 	// +optional
 	Volumes []corev1.Volume `json:"volumes,omitempty"`
@@ -110,15 +175,211 @@ type DaskSpec struct {
 
 	// Specifies the Scheduler specfic variables.
 	// +optional
-	Scheduler *DaskDeploymentSpec `json:"scheduler,omitempty"`
+	Scheduler *DaskSchedulerSpec `json:"scheduler,omitempty"`
 
 	// Specifies the Worker specfic variables.
 	// +optional
-	Worker *DaskDeploymentSpec `json:"worker,omitempty"`
+	Worker *DaskWorkerSpec `json:"worker,omitempty"`
 
 	// Specifies the Jupyter notebook specfic variables.
 	// +optional
-	Notebook *DaskDeploymentSpec `json:"notebook,omitempty"`
+	Notebook *DaskNotebookSpec `json:"notebook,omitempty"`
+
+	// Specifies additional named groups of workers, each with their own
+	// Deployment - replicas defaults to 0 when groups are given
+	// +optional
+	WorkerGroups []DaskWorkerGroup `json:"workerGroups,omitempty"`
+
+	// Secure the Scheduler, worker and client communications with TLS,
+	// using certificates issued from a CA managed by the operator
+	// +optional
+	TLS *DaskTLSSpec `json:"tls,omitempty"`
+
+	// +kubebuilder:validation:Enum=default;restricted
+
+	// Run the cluster and its DaskJobs within a Pod Security Standard:
+	// restricted runs them as a non-root user, without capabilities and on a
+	// read-only root filesystem - default: default
+	// +optional
+	SecurityProfile string `json:"securityProfile,omitempty"`
+
+	// Limit how many of the cluster Pods voluntary disruptions, such as node
+	// drains, may evict at once
+	// +optional
+	DisruptionBudget *DaskDisruptionBudgetSpec `json:"disruptionBudget,omitempty"`
+
+	// Dask configuration, as in a dask.yaml, for all of the components and
+	// DaskJobs of the cluster - eg: distributed: {worker: {memory: {spill: 0.85}}}
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	DaskConfig *runtime.RawExtension `json:"daskConfig,omitempty"`
+}
+
+// DaskWorkerGroup - a named group of workers layered on the worker settings
+type DaskWorkerGroup struct {
+	// +kubebuilder:validation:MaxLength=20
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+
+	// Name of the group - the Deployment is dask-worker-<name>-<group>
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Minimum=0
+
+	// Number of workers in the group - default: 1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Settings that replace those of the workers for this group
+	DaskDeploymentSpec `json:",inline"`
+}
+
+// DaskIngressSpec - how the Ingress for the Dask is set up
+type DaskIngressSpec struct {
+	// The IngressClass to use - default: the cluster default class
+	// +optional
+	ClassName *string `json:"className,omitempty"`
+
+	// Annotations for the Ingress - default: the nginx x-forwarded-prefix and
+	// ssl-redirect settings
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// TLS for the hostnames, each with the Secret holding its certificate
+	// +optional
+	TLS []networkingv1.IngressTLS `json:"tls,omitempty"`
+}
+
+// DaskGatewaySpec - the Gateway that the HTTPRoutes for the Dask attach to
+type DaskGatewaySpec struct {
+	// The parent Gateway
+	ParentRef DaskGatewayParentRef `json:"parentRef"`
+
+	// The Pods of the Gateway implementation, that the NetworkPolicy lets
+	// through to the Scheduler dashboard - default: the class gatewayFrom,
+	// or else any Pod
+	// +optional
+	From []networkingv1.NetworkPolicyPeer `json:"from,omitempty"`
+}
+
+// DaskGatewayParentRef - a reference to a Gateway, and optionally one of its
+// listeners
+type DaskGatewayParentRef struct {
+	// Name of the Gateway
+	Name string `json:"name"`
+
+	// Namespace of the Gateway - default: the namespace of the Dask
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the Gateway listener to attach to - default: all of them
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// DaskNotebookSpec - the Jupyter Notebook settings
+type DaskNotebookSpec struct {
+	// +kubebuilder:validation:Enum=lab;classic
+
+	// Run JupyterLab, with the Dask extension wired to the cluster, or the
+	// classic Notebook - default: classic
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^/`
+
+	// The base URL the Notebook is served under, for a path-prefixed
+	// Ingress - default: /
+	// +optional
+	BaseURL string `json:"baseURL,omitempty"`
+
+	// How the Notebook Service is exposed - default: ClusterIP
+	// +optional
+	Service *DaskServiceSpec `json:"service,omitempty"`
+
+	// Settings that replace the general ones for the Notebook
+	DaskDeploymentSpec `json:",inline"`
+}
+
+// DaskSchedulerSpec - the Scheduler settings
+type DaskSchedulerSpec struct {
+	// How the Scheduler Service is exposed - default: ClusterIP
+	// +optional
+	Service *DaskServiceSpec `json:"service,omitempty"`
+
+	// Settings that replace the general ones for the Scheduler
+	DaskDeploymentSpec `json:",inline"`
+}
+
+// DaskWorkerSpec - the worker settings
+type DaskWorkerSpec struct {
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+
+	// Run the workers in a Deployment, or in a StatefulSet that gives each
+	// worker a stable name and a volume of its own for the Dask local
+	// directory - default: Deployment
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// The volume that each worker of a StatefulSet spills to
+	// +optional
+	Storage *DaskWorkerStorageSpec `json:"storage,omitempty"`
+
+	// Settings that replace the general ones for the workers
+	DaskDeploymentSpec `json:",inline"`
+}
+
+// DaskWorkerStorageSpec - the PersistentVolumeClaim of each worker, mounted
+// as the Dask local directory
+type DaskWorkerStorageSpec struct {
+	// The StorageClass of the volumes - default: the cluster default class
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// The size of the volumes - default: 10Gi
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+}
+
+// DaskServiceSpec - how a Service is exposed outside of the cluster
+type DaskServiceSpec struct {
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+
+	// The Service type - default: ClusterIP
+	// +optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Annotations for the Service, eg: for the cloud load balancer
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// The client IP ranges allowed through a LoadBalancer
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// Fixed node ports by Service port name: scheduler and bokeh for the
+	// Scheduler, jupyter for the Notebook - default: allocated by Kubernetes
+	// +optional
+	NodePorts map[string]int32 `json:"nodePorts,omitempty"`
+}
+
+// DaskJupyterAuthSpec - the credentials for the Jupyter Notebook, which are
+// always held in a Secret
+type DaskJupyterAuthSpec struct {
+	// +kubebuilder:validation:Enum=password;token
+
+	// Log in with a password or a token - default: password
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// The Secret key holding the password or token - when not given, one is
+	// generated into the Secret jupyter-auth-<name>
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+
+	// The password is already hashed, eg: with notebook.auth.passwd()
+	// +optional
+	Hashed bool `json:"hashed,omitempty"`
 }
 
 // DaskDeploymentSpec - shared structure of configurable attributes
@@ -154,24 +415,272 @@ type DaskDeploymentSpec struct {
 	// Specifies the Environment variables.
 	// +optional
 	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// A partial Pod template, strategically merged over the one generated
+	// for the component - eg: for init containers, sidecars or probes
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PodTemplate *runtime.RawExtension `json:"podTemplate,omitempty"`
+
+	// The PriorityClass of the Pods
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Dask configuration that is merged over that of the cluster, key by key,
+	// for the component
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	DaskConfig *runtime.RawExtension `json:"daskConfig,omitempty"`
+
+	// Dask abstract resources that the workers advertise eg: GPU: "1"
+	// +optional
+	DaskResources map[string]string `json:"daskResources,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+
+	// Threads per worker process - default: the CPU limit, or request,
+	// shared between the processes
+	// +optional
+	NThreads *int32 `json:"nthreads,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+
+	// Worker processes in each Pod - default: 1
+	// +optional
+	NWorkers *int32 `json:"nworkers,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^(0?\.[0-9]+|1(\.0+)?)$`
+
+	// The fraction of the memory limit, or request, that the worker
+	// processes share as their memory limit eg: "0.8" - default: 0.8
+	// +optional
+	MemoryFraction string `json:"memoryFraction,omitempty"`
+
+	// The container entrypoint, in place of the generated start script - the
+	// environment of the component, such as DASK_SCHEDULER and the ports, is
+	// still set
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Arguments to the command, or to the start script - the generated
+	// scripts add them to the dask-scheduler, dask-worker or jupyter command
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// A key of a ConfigMap that is mounted in place of the generated start
+	// script, eg: to run dask-cuda-worker
+	// +optional
+	StartScript *corev1.ConfigMapKeySelector `json:"startScript,omitempty"`
 }
 
+// DaskTLSSpec - the certificates issued for TLS.  The operator creates a
+// CA for each Dask in the Secret dask-ca-<name>, and the certificates for
+// the scheduler, worker and client roles in dask-tls-<name>-<role>.
+type DaskTLSSpec struct {
+	// How long the issued certificates are valid for - default: 2160h (90 days)
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// How long before expiry the certificates are reissued - default: 720h (30 days)
+	// +optional
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+}
+
+// DaskDisruptionBudgetSpec - the PodDisruptionBudgets for the cluster.  The
+// Scheduler is protected in dask-scheduler-<name>, and the workers, across
+// all of the groups, in dask-worker-<name>.
+type DaskDisruptionBudgetSpec struct {
+	// Keep the Scheduler running through voluntary disruptions - default: true
+	// +optional
+	Scheduler *bool `json:"scheduler,omitempty"`
+
+	// The number, or percentage, of workers that must stay available - the
+	// workers are only protected when this or maxUnavailable is set
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// The number, or percentage, of workers that may be evicted at once
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// DaskAdaptiveSpec - bounds and pacing for adaptive worker scaling
+type DaskAdaptiveSpec struct {
+	// +kubebuilder:validation:Minimum=0
+
+	// Minimum number of workers - default: 0
+	// +optional
+	Minimum int32 `json:"minimum,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+
+	// Maximum number of workers
+	Maximum int32 `json:"maximum"`
+
+	// Minimum time between scaling actions, and how long the load must stay
+	// low before workers are removed - default: 1m
+	// +optional
+	Cooldown *metav1.Duration `json:"cooldown,omitempty"`
+}
+
+// DaskAdaptiveStatus - the observed state of adaptive worker scaling
+type DaskAdaptiveStatus struct {
+	// Number of workers the cluster is currently scaled to
+	Replicas int32 `json:"replicas"`
+
+	// Number of workers asked for by the last Scheduler poll
+	Target int32 `json:"target"`
+
+	// Time of the last scaling action
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+
+	// Time that the target first fell below the current number of workers
+	// +optional
+	ScaleDownSince *metav1.Time `json:"scaleDownSince,omitempty"`
+}
+
+// Condition types reported on a Dask
+const (
+	// DaskReady - all of the other conditions are True
+	DaskReady = "Ready"
+	// DaskSchedulerReady - the Scheduler Deployment is available
+	DaskSchedulerReady = "SchedulerReady"
+	// DaskWorkersReady - all of the worker Deployments are fully available
+	DaskWorkersReady = "WorkersReady"
+	// DaskNotebookReady - the Jupyter Notebook Deployment is available
+	DaskNotebookReady = "NotebookReady"
+	// DaskIngressReady - the Ingress has been given an address
+	DaskIngressReady = "IngressReady"
+	// DaskRoutesAccepted - the HTTPRoutes have been accepted by the Gateway
+	DaskRoutesAccepted = "RoutesAccepted"
+	// DaskHibernated - the cluster has been idle for spec.idleTimeout, and
+	// the workers have been scaled to zero
+	DaskHibernated = "Hibernated"
+	// DaskSuspended - spec.suspend is set, and the Deployments have been
+	// scaled to zero
+	DaskSuspended = "Suspended"
+	// DaskPolicyCompliant - the cluster keeps to the DaskPolicies of its
+	// namespace, which is only reported when there are any
+	DaskPolicyCompliant = "PolicyCompliant"
+)
+
+// DaskWakeUpAnnotation wakes up a hibernated Dask when set to any value -
+// the controller removes it once the cluster is woken
+const DaskWakeUpAnnotation = "analytics.piersharding.com/wake-up"
+
+// DaskExtendLeaseAnnotation pushes back the expiry of a Dask by the given
+// duration eg: 2h - the controller removes it once the lease is extended
+const DaskExtendLeaseAnnotation = "analytics.piersharding.com/extend-lease"
+
 // DaskStatus defines the observed state of Dask
 type DaskStatus struct {
-	Replicas  int32  `json:"replicas"`
-	Succeeded int32  `json:"succeeded"`
-	State     string `json:"state"`
-	Resources string `json:"resources"`
+	// The generation of the Dask last handled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Number of Pods in the worker Deployment scaled by spec.replicas
+	Replicas int32 `json:"replicas"`
+
+	// Label selector for the Pods of the worker Deployment scaled by spec.replicas
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// Number of component Deployments found
+	// +optional
+	Components int32 `json:"components,omitempty"`
+
+	// Number of component Deployments that are fully available
+	Succeeded int32 `json:"succeeded"`
+
+	// Summary of the cluster state: Building, Running, Hibernated, Suspended or Error
+	State string `json:"state"`
+
+	// Number of workers asked for across all of the worker Deployments
+	// +optional
+	Workers int32 `json:"workers,omitempty"`
+
+	// Number of workers that are ready
+	// +optional
+	ReadyWorkers int32 `json:"readyWorkers,omitempty"`
+
+	// Address for Dask clients to connect to the Scheduler on
+	// +optional
+	SchedulerAddress string `json:"schedulerAddress,omitempty"`
+
+	// URL of the Scheduler monitor (bokeh) dashboard
+	// +optional
+	DashboardURL string `json:"dashboardURL,omitempty"`
+
+	// URL of the Jupyter Notebook
+	// +optional
+	JupyterURL string `json:"jupyterURL,omitempty"`
+
+	// Address of the Scheduler for clients outside the cluster, once its
+	// LoadBalancer Service has been given one
+	// +optional
+	SchedulerExternalAddress string `json:"schedulerExternalAddress,omitempty"`
+
+	// URL of the Jupyter Notebook outside the cluster, once its LoadBalancer
+	// Service has been given an address
+	// +optional
+	JupyterExternalURL string `json:"jupyterExternalURL,omitempty"`
+
+	// Name of the Secret holding the Jupyter Notebook password or token
+	// +optional
+	JupyterAuthSecret string `json:"jupyterAuthSecret,omitempty"`
+
+	// Adaptive scaling state, when enabled
+	// +optional
+	Adaptive *DaskAdaptiveStatus `json:"adaptive,omitempty"`
+
+	// Time since which the Scheduler has had no tasks and no clients
+	// +optional
+	IdleSince *metav1.Time `json:"idleSince,omitempty"`
+
+	// Time at which the TLS certificates expire, when tls is enabled
+	// +optional
+	CertificatesNotAfter *metav1.Time `json:"certificatesNotAfter,omitempty"`
+
+	// Time at which the Dask will be deleted, including any lease extensions
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Total time that the lease has been extended by
+	// +optional
+	LeaseExtension *metav1.Duration `json:"leaseExtension,omitempty"`
+
+	// Hash of the extend-lease annotation taken up, until it is removed -
+	// so that the extension is only counted once
+	// +optional
+	LeaseExtensionApplied string `json:"leaseExtensionApplied,omitempty"`
+
+	// Shortest lead time at which the expiry has been warned of
+	// +optional
+	ExpiryWarned *metav1.Duration `json:"expiryWarned,omitempty"`
+
+	// The latest observations of the cluster components
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 // Dask is the Schema for the dasks API
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
-// +kubebuilder:printcolumn:name="Components",type="integer",JSONPath=".status.replicas",description="The number of Components Requested in the Dask",priority=0
-// +kubebuilder:printcolumn:name="Succeeded",type="integer",JSONPath=".status.succeeded",description="The number of Components Launched in the Dask",priority=0
-// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The number of Components Requested in the Dask",priority=0
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
 // +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state",description="Status of the Dask",priority=0
-// +kubebuilder:printcolumn:name="Resources",type="string",JSONPath=".status.resources",description=" Resource details of the Dask",priority=1
+// +kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyWorkers",description="The number of workers ready",priority=0
+// +kubebuilder:printcolumn:name="Workers",type="integer",JSONPath=".status.workers",description="The number of workers requested",priority=0
+// +kubebuilder:printcolumn:name="Scheduler",type="string",JSONPath=".status.schedulerAddress",description="Address of the Dask Scheduler",priority=0
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time since the Dask was created",priority=0
+// +kubebuilder:printcolumn:name="Components",type="integer",JSONPath=".status.components",description="The number of Components Requested in the Dask",priority=1
+// +kubebuilder:printcolumn:name="Succeeded",type="integer",JSONPath=".status.succeeded",description="The number of Components Launched in the Dask",priority=1
+// +kubebuilder:printcolumn:name="Expires",type="string",JSONPath=".status.expiresAt",description="Time at which the Dask is deleted",priority=1
+// +kubebuilder:printcolumn:name="Dashboard",type="string",JSONPath=".status.dashboardURL",description="URL of the Dask Scheduler dashboard",priority=1
 type Dask struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -53,7 +53,7 @@ var _ = Context("Inside of a new namespace", func() {
 				},
 				Spec: DaskSpec{
 					Jupyter:          true,
-					Replicas:         initialReplicas,
+					Replicas:         &initialReplicas,
 					Image:            "piersharding/arl-dask:latest",
 					JupyterIngress:   "notebook.dask.local",
 					SchedulerIngress: "scheduler.dask.local",
@@ -61,8 +61,8 @@ var _ = Context("Inside of a new namespace", func() {
 					ImagePullPolicy:  "IfNotPresent",
 					Env: []corev1.EnvVar{{Name: "x",
 						Value: "y"}},
-					Worker: &DaskDeploymentSpec{Env: []corev1.EnvVar{{Name: "x",
-						Value: "y"}}},
+					Worker: &DaskWorkerSpec{DaskDeploymentSpec: DaskDeploymentSpec{Env: []corev1.EnvVar{{Name: "x",
+						Value: "y"}}}},
 				},
 			}
 
@@ -88,9 +88,26 @@ var _ = Context("Inside of a new namespace", func() {
 			Expect(fetched.validateDaskName()).Should(HaveOccurred())
 			By("Setting defaults")
 			fetched.Spec.ImagePullPolicy = ""
-			Expect(fetched.Spec.ImagePullPolicy).To(Equal(""))
+			fetched.Spec.Replicas = nil
 			fetched.Default()
-			Expect(fetched.Spec.ImagePullPolicy).To(Equal("IfNotPresent"))
+			// the pull policy is left for the DaskClusterClass
+			Expect(fetched.Spec.ImagePullPolicy).To(Equal(""))
+			Expect(*fetched.Spec.Replicas).To(Equal(int32(5)))
+
+			By("warning about the deprecated jupyterPassword")
+			Expect(fetched.deprecationWarnings()).To(BeEmpty())
+			fetched.Spec.JupyterPassword = "password"
+			Expect(fetched.deprecationWarnings()).To(ConsistOf(ContainSubstring("spec.jupyterPassword is deprecated")))
+			fetched.Spec.JupyterPassword = ""
+
+			By("rejecting a change of replicas while adaptive scaling is on")
+			adaptive := fetched.DeepCopy()
+			adaptive.Spec.Adaptive = &DaskAdaptiveSpec{Maximum: 10}
+			scaled := adaptive.DeepCopy()
+			scaled.Spec.Replicas = &initialReplicas
+			Expect(scaled.ValidateUpdate(adaptive)).Should(HaveOccurred())
+			scaled.Spec.Adaptive = nil
+			Expect(scaled.ValidateUpdate(adaptive)).To(BeNil())
 
 			By("deleting the created object")
 			Expect(k8sClient.Delete(ctx, created)).To(Succeed())
@@ -18,24 +18,42 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	validationutils "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	ctrl "sigs.k8s.io/controller-runtime"
-	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 var dasklog = logf.Log.WithName("dask-resource")
 
-func (r *Dask) SetupWebhookWithManager(mgr ctrl.Manager) error {
+// SetupWebhookWithManager registers the defaulting webhook, and the
+// validating webhook that reads the DaskPolicies and DaskClusterClasses
+// through the client of the manager - the default image is that of the
+// operator, which a Dask runs when neither it nor its class gives one
+func (r *Dask) SetupWebhookWithManager(mgr ctrl.Manager, defaultImage string) error {
 	dasklog.Info("Activating Webhook")
-	return ctrl.NewWebhookManagedBy(mgr).
-		For(r).
-		Complete()
+	server := mgr.GetWebhookServer()
+	server.Register("/mutate-analytics-piersharding-com-v1-dask", admission.DefaultingWebhookFor(r))
+	server.Register("/validate-analytics-piersharding-com-v1-dask", &webhook.Admission{Handler: &daskValidator{reader: mgr.GetClient(), defaultImage: defaultImage}})
+	return nil
 }
 
 // +kubebuilder:webhook:path=/mutate-analytics-piersharding-com-v1-dask,mutating=true,failurePolicy=fail,groups=analytics.piersharding.com,resources=dasks,verbs=create;update,versions=v1,name=mdask.piersharding.com
@@ -46,13 +64,8 @@ var _ webhook.Defaulter = &Dask{}
 func (r *Dask) Default() {
 	dasklog.Info("default", "name", r.Name)
 
-	if r.Spec.Image == "" {
-		r.Spec.Image = "jupyter/scipy-notebook:latest"
-	}
-
-	if r.Spec.ImagePullPolicy == "" {
-		r.Spec.ImagePullPolicy = "IfNotPresent"
-	}
+	// the image and pull policy are left to the DaskClusterClass, and then
+	// the operator defaults, when the Dask is reconciled
 
 	// if r.Spec.Daemon == nil {
 	// 	r.Spec.Daemon = new(bool)
@@ -66,31 +79,33 @@ func (r *Dask) Default() {
 	// 	*r.Spec.Replicas = 1
 	// }
 
-	if r.Spec.Replicas == 0 {
-		r.Spec.Replicas = int32(5)
-	}
-	if r.Spec.JupyterPassword == "" {
-		r.Spec.JupyterPassword = "password"
+	if r.Spec.Replicas == nil {
+		r.Spec.Replicas = new(int32)
+		if len(r.Spec.WorkerGroups) == 0 {
+			*r.Spec.Replicas = 5
+		}
 	}
 }
 
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
-// +kubebuilder:webhook:verbs=create;update,path=/validate-analytics-piersharding-com-v1-dask,mutating=false,failurePolicy=fail,groups=analytics.piersharding.com,resources=dasks,versions=v1,name=vdask.piersharding.com
+// +kubebuilder:webhook:verbs=create;update,path=/validate-analytics-piersharding-com-v1-dask,mutating=false,failurePolicy=fail,groups=analytics.piersharding.com,resources=dasks;dasks/scale,versions=v1,name=vdask.piersharding.com
 
 var _ webhook.Validator = &Dask{}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+// ValidateCreate implements webhook.Validator - without the DaskPolicies,
+// which only the webhook can read
 func (r *Dask) ValidateCreate() error {
 	dasklog.Info("validate create", "name", r.Name)
 
-	return r.validateDask()
+	return r.validateDask(context.Background(), nil, nil)
 }
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+// ValidateUpdate implements webhook.Validator - without the DaskPolicies,
+// which only the webhook can read
 func (r *Dask) ValidateUpdate(old runtime.Object) error {
 	dasklog.Info("validate update", "name", r.Name)
 
-	return r.validateDask()
+	return r.validateDask(context.Background(), old.(*Dask), nil)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -101,7 +116,76 @@ func (r *Dask) ValidateDelete() error {
 	return nil
 }
 
-func (r *Dask) validateDask() error {
+// daskValidator is the validating webhook for Dasks, which holds them to the
+// DaskPolicies read through its reader as well
+type daskValidator struct {
+	reader       client.Reader
+	defaultImage string
+	decoder      *admission.Decoder
+}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *daskValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler
+func (v *daskValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.SubResource == "scale" {
+		return v.handleScale(ctx, req)
+	}
+	dask := &Dask{}
+	if err := v.decoder.Decode(req, dask); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	var old *Dask
+	if req.Operation == admissionv1.Update {
+		old = &Dask{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	dasklog.Info("validate", "name", dask.Name, "operation", req.Operation)
+	return validationResponse(dask.validateDask(ctx, old, v)).WithWarnings(dask.deprecationWarnings()...)
+}
+
+// deprecationWarnings lists the deprecated settings in use, which are
+// returned to the client as admission warnings
+func (r *Dask) deprecationWarnings() []string {
+	var warnings []string
+	if r.Spec.JupyterPassword != "" {
+		warnings = append(warnings, "spec.jupyterPassword is deprecated and will be removed, as it is plaintext in the Dask - "+
+			"it only seeds the generated Secret jupyter-auth-"+r.Name+", use spec.jupyterAuth instead")
+	}
+	return warnings
+}
+
+// handleScale checks a change of replicas through the scale subresource,
+// which carries a Scale rather than the Dask
+func (v *daskValidator) handleScale(ctx context.Context, req admission.Request) admission.Response {
+	scale := &autoscalingv1.Scale{}
+	if err := json.Unmarshal(req.Object.Raw, scale); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	old := &Dask{}
+	if err := v.reader.Get(ctx, client.ObjectKey{Namespace: req.Namespace, Name: req.Name}, old); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	dask := old.DeepCopy()
+	dask.Spec.Replicas = &scale.Spec.Replicas
+	dasklog.Info("validate scale", "name", dask.Name, "replicas", scale.Spec.Replicas)
+	if err := dask.validateAdaptiveReplicas(old); err != nil {
+		return validationResponse(apierrors.NewInvalid(
+			schema.GroupKind{Group: "analytics.piersharding.com", Kind: "Dask"},
+			dask.Name, field.ErrorList{err}))
+	}
+	return admission.Allowed("")
+}
+
+// validateDask checks a new Dask, or the update of an old one, and against
+// the DaskPolicies when it comes through the webhook
+func (r *Dask) validateDask(ctx context.Context, old *Dask, v *daskValidator) error {
 	var allErrs field.ErrorList
 	if err := r.validateDaskName(); err != nil {
 		allErrs = append(allErrs, err)
@@ -109,6 +193,12 @@ func (r *Dask) validateDask() error {
 	if err := r.validateDaskSpec(); err != nil {
 		allErrs = append(allErrs, err)
 	}
+	if old != nil {
+		allErrs = append(allErrs, r.validateDaskUpdate(old)...)
+	}
+	if v != nil {
+		allErrs = append(allErrs, v.validatePolicies(ctx, r, old == nil)...)
+	}
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -124,6 +214,220 @@ func (r *Dask) validateDaskSpec() *field.Error {
 	// return validateScheduleFormat(
 	// 	r.Spec.Schedule,
 	// 	field.NewPath("spec").Child("schedule"))
+	if r.Spec.Adaptive != nil && r.Spec.Adaptive.Minimum > r.Spec.Adaptive.Maximum {
+		return field.Invalid(field.NewPath("spec").Child("adaptive").Child("minimum"), r.Spec.Adaptive.Minimum, "must not be greater than maximum")
+	}
+	if r.Spec.IdleTimeout != nil && r.Spec.IdleTimeout.Duration <= 0 {
+		return field.Invalid(field.NewPath("spec").Child("idleTimeout"), r.Spec.IdleTimeout.Duration.String(), "must be greater than zero")
+	}
+	if auth := r.Spec.JupyterAuth; auth != nil && auth.Hashed && auth.Type == "token" {
+		return field.Invalid(field.NewPath("spec").Child("jupyterAuth").Child("hashed"), auth.Hashed, "only a password can be hashed")
+	}
+	if tls := r.Spec.TLS; tls != nil && tls.Duration != nil && tls.RenewBefore != nil && tls.RenewBefore.Duration >= tls.Duration.Duration {
+		return field.Invalid(field.NewPath("spec").Child("tls").Child("renewBefore"), tls.RenewBefore.Duration.String(), "must be less than duration")
+	}
+	for i, warning := range r.Spec.ExpiryWarnings {
+		if warning.Duration <= 0 {
+			return field.Invalid(field.NewPath("spec").Child("expiryWarnings").Index(i), warning.Duration.String(), "must be greater than zero")
+		}
+	}
+	if r.Spec.Scheduler != nil {
+		if err := validateStartCommand(&r.Spec.Scheduler.DaskDeploymentSpec, field.NewPath("spec").Child("scheduler")); err != nil {
+			return err
+		}
+		if err := validateService(r.Spec.Scheduler.Service, field.NewPath("spec").Child("scheduler").Child("service"), "scheduler", "bokeh"); err != nil {
+			return err
+		}
+	}
+	if r.Spec.Notebook != nil {
+		if err := validateStartCommand(&r.Spec.Notebook.DaskDeploymentSpec, field.NewPath("spec").Child("notebook")); err != nil {
+			return err
+		}
+		if err := validateService(r.Spec.Notebook.Service, field.NewPath("spec").Child("notebook").Child("service"), "jupyter"); err != nil {
+			return err
+		}
+	}
+	if r.Spec.SecurityProfile == "restricted" {
+		for i, volume := range r.Spec.Volumes {
+			if volume.HostPath != nil {
+				return field.Forbidden(field.NewPath("spec").Child("volumes").Index(i).Child("hostPath"), "hostPath volumes are not allowed by the restricted securityProfile")
+			}
+		}
+	}
+	if worker := r.Spec.Worker; worker != nil {
+		if worker.Storage != nil && worker.Kind != "StatefulSet" {
+			return field.Forbidden(field.NewPath("spec").Child("worker").Child("storage"), "only applies to workers of the StatefulSet kind")
+		}
+		if err := validateMemoryFraction(worker.MemoryFraction, field.NewPath("spec").Child("worker").Child("memoryFraction")); err != nil {
+			return err
+		}
+		if err := validateStartCommand(&worker.DaskDeploymentSpec, field.NewPath("spec").Child("worker")); err != nil {
+			return err
+		}
+	}
+	if err := validateDisruptionBudget(r.Spec.DisruptionBudget, r.minWorkerCount(), field.NewPath("spec").Child("disruptionBudget")); err != nil {
+		return err
+	}
+	groups := map[string]bool{}
+	for i, group := range r.Spec.WorkerGroups {
+		if groups[group.Name] {
+			return field.Duplicate(field.NewPath("spec").Child("workerGroups").Index(i).Child("name"), group.Name)
+		}
+		groups[group.Name] = true
+		if err := validateMemoryFraction(group.MemoryFraction, field.NewPath("spec").Child("workerGroups").Index(i).Child("memoryFraction")); err != nil {
+			return err
+		}
+		if err := validateStartCommand(&group.DaskDeploymentSpec, field.NewPath("spec").Child("workerGroups").Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateDaskUpdate checks that an update does not change how the workers
+// are run - a StatefulSet cannot be given other volumes once it is created,
+// and the workers cannot be moved between a Deployment and a StatefulSet
+// while they are running
+func (r *Dask) validateDaskUpdate(old *Dask) field.ErrorList {
+	var allErrs field.ErrorList
+	worker := field.NewPath("spec").Child("worker")
+	var oldWorker, newWorker DaskWorkerSpec
+	if old.Spec.Worker != nil {
+		oldWorker = *old.Spec.Worker
+	}
+	if r.Spec.Worker != nil {
+		newWorker = *r.Spec.Worker
+	}
+	if workerKind(oldWorker.Kind) != workerKind(newWorker.Kind) {
+		allErrs = append(allErrs, field.Invalid(worker.Child("kind"), workerKind(newWorker.Kind), "may not be changed - delete and recreate the Dask to change it"))
+	}
+	if !apiequality.Semantic.DeepEqual(oldWorker.Storage, newWorker.Storage) {
+		allErrs = append(allErrs, field.Forbidden(worker.Child("storage"), "may not be changed - delete and recreate the Dask to change it"))
+	}
+	if err := r.validateAdaptiveReplicas(old); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	return allErrs
+}
+
+// validateAdaptiveReplicas rejects a change of replicas, by hand or through
+// the scale subresource, while the workers are sized by adaptive scaling -
+// it would have no effect
+func (r *Dask) validateAdaptiveReplicas(old *Dask) *field.Error {
+	if r.Spec.Adaptive == nil || apiequality.Semantic.DeepEqual(old.Spec.Replicas, r.Spec.Replicas) {
+		return nil
+	}
+	return field.Forbidden(field.NewPath("spec").Child("replicas"), "is managed by adaptive scaling - change spec.adaptive, or remove it to scale by hand")
+}
+
+// workerKind gives the kind of resource that the workers are run in
+func workerKind(kind string) string {
+	if kind == "" {
+		return "Deployment"
+	}
+	return kind
+}
+
+// validateMemoryFraction checks that the workers are given some, but no
+// more than all, of their memory
+func validateMemoryFraction(fraction string, fldPath *field.Path) *field.Error {
+	if fraction == "" {
+		return nil
+	}
+	value, err := strconv.ParseFloat(fraction, 64)
+	if err != nil || value <= 0 || value > 1 {
+		return field.Invalid(fldPath, fraction, "must be greater than 0 and no more than 1")
+	}
+	return nil
+}
+
+// validateStartCommand checks that a component is not given both a command
+// and a start script, which the command would never run
+func validateStartCommand(spec *DaskDeploymentSpec, fldPath *field.Path) *field.Error {
+	if len(spec.Command) > 0 && spec.StartScript != nil {
+		return field.Forbidden(fldPath.Child("startScript"), "may not be given with command")
+	}
+	if spec.StartScript != nil && spec.StartScript.Name == "" {
+		return field.Required(fldPath.Child("startScript").Child("name"), "the ConfigMap holding the start script")
+	}
+	return nil
+}
+
+// validateService checks that node ports are only fixed for the named ports
+// of a Service that has them
+func validateService(service *DaskServiceSpec, fldPath *field.Path, ports ...string) *field.Error {
+	if service == nil || len(service.NodePorts) == 0 {
+		return nil
+	}
+	if service.Type != corev1.ServiceTypeNodePort && service.Type != corev1.ServiceTypeLoadBalancer {
+		return field.Invalid(fldPath.Child("nodePorts"), service.NodePorts, "needs a NodePort or LoadBalancer type")
+	}
+	for name := range service.NodePorts {
+		found := false
+		for _, port := range ports {
+			found = found || name == port
+		}
+		if !found {
+			return field.NotSupported(fldPath.Child("nodePorts").Key(name), name, ports)
+		}
+	}
+	return nil
+}
+
+// workerCount is the most workers that the Dask runs, across all of the groups
+func (r *Dask) workerCount() int32 {
+	if r.Spec.Adaptive != nil {
+		return r.Spec.Adaptive.Maximum
+	}
+	var workers int32 = 5
+	if r.Spec.Replicas != nil {
+		workers = *r.Spec.Replicas
+	} else if len(r.Spec.WorkerGroups) > 0 {
+		workers = 0
+	}
+	for _, group := range r.Spec.WorkerGroups {
+		if group.Replicas != nil {
+			workers += *group.Replicas
+		} else {
+			workers++
+		}
+	}
+	return workers
+}
+
+// minWorkerCount is the fewest workers that the Dask runs - the adaptive
+// minimum, which the workers can be scaled down to at any time
+func (r *Dask) minWorkerCount() int32 {
+	if r.Spec.Adaptive != nil {
+		return r.Spec.Adaptive.Minimum
+	}
+	return r.workerCount()
+}
+
+// validateDisruptionBudget checks that the worker PodDisruptionBudget can
+// be met by the fewest workers that the Dask runs
+func validateDisruptionBudget(budget *DaskDisruptionBudgetSpec, workers int32, fldPath *field.Path) *field.Error {
+	if budget == nil {
+		return nil
+	}
+	if budget.MinAvailable != nil && budget.MaxUnavailable != nil {
+		return field.Invalid(fldPath.Child("maxUnavailable"), budget.MaxUnavailable.String(), "may not be set with minAvailable")
+	}
+	for name, value := range map[string]*intstr.IntOrString{"minAvailable": budget.MinAvailable, "maxUnavailable": budget.MaxUnavailable} {
+		if value == nil {
+			continue
+		}
+		scaled, err := intstr.GetScaledValueFromIntOrPercent(value, 100, true)
+		if err != nil {
+			return field.Invalid(fldPath.Child(name), value.String(), err.Error())
+		}
+		if scaled < 0 || (value.Type == intstr.String && scaled > 100) {
+			return field.Invalid(fldPath.Child(name), value.String(), "must be between 0 and 100%, or a number not less than 0")
+		}
+	}
+	if min := budget.MinAvailable; min != nil && min.Type == intstr.Int && min.IntVal > workers {
+		return field.Invalid(fldPath.Child("minAvailable"), min.IntVal, fmt.Sprintf("must not be greater than the %d workers that the Dask can be scaled down to", workers))
+	}
 	return nil
 }
 
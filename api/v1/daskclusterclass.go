@@ -0,0 +1,125 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClassNotFoundError is the error for a Dask that names a class that does
+// not exist
+type ClassNotFoundError string
+
+func (e ClassNotFoundError) Error() string {
+	return fmt.Sprintf("DaskClusterClass %s not found", string(e))
+}
+
+// ClassFor finds the DaskClusterClass that a Dask takes its defaults from:
+// the one it names, or else the default class.  A Dask without either has
+// no class, and a Dask naming a class that does not exist is an error.
+func ClassFor(ctx context.Context, c client.Reader, dask *Dask) (*DaskClusterClass, error) {
+	if dask.Spec.ClassName != "" {
+		class := &DaskClusterClass{}
+		if err := c.Get(ctx, client.ObjectKey{Name: dask.Spec.ClassName}, class); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, ClassNotFoundError(dask.Spec.ClassName)
+			}
+			return nil, err
+		}
+		return class, nil
+	}
+	return defaultClass(ctx, c)
+}
+
+// defaultClass finds the class annotated as the default - the newest of
+// them should there be more than one
+func defaultClass(ctx context.Context, c client.Reader) (*DaskClusterClass, error) {
+	var classes DaskClusterClassList
+	if err := c.List(ctx, &classes); err != nil {
+		return nil, err
+	}
+	var class *DaskClusterClass
+	for i := range classes.Items {
+		item := &classes.Items[i]
+		if item.Annotations[DefaultClassAnnotation] != "true" {
+			continue
+		}
+		if class == nil || class.CreationTimestamp.Before(&item.CreationTimestamp) {
+			class = item
+		}
+	}
+	return class, nil
+}
+
+// ApplyClass - fill in the settings that a Dask leaves unset from the
+// defaults of its class
+func ApplyClass(dask Dask, class *DaskClusterClass) Dask {
+	if class == nil {
+		return dask
+	}
+	defaults := class.Spec
+	dask = *dask.DeepCopy()
+	if dask.Spec.Image == "" {
+		dask.Spec.Image = defaults.Image
+	}
+	if dask.Spec.ImagePullPolicy == "" {
+		dask.Spec.ImagePullPolicy = defaults.ImagePullPolicy
+	}
+	if dask.Spec.Resources == nil {
+		dask.Spec.Resources = defaults.Resources
+	}
+	if dask.Spec.NodeSelector == nil {
+		dask.Spec.NodeSelector = defaults.NodeSelector
+	}
+	if dask.Spec.Tolerations == nil {
+		dask.Spec.Tolerations = defaults.Tolerations
+	}
+	if dask.Spec.SecurityProfile == "" {
+		dask.Spec.SecurityProfile = defaults.SecurityProfile
+	}
+	if dask.Spec.DisablePolicies == nil && defaults.DisablePolicies {
+		dask.Spec.DisablePolicies = &defaults.DisablePolicies
+	}
+
+	// the hostnames in the domain are unique to each Dask, and only given
+	// to a Dask that asks to be exposed with ingress: or gateway:
+	if domain := defaults.IngressDomain; domain != "" && (dask.Spec.Ingress != nil || dask.Spec.Gateway != nil) {
+		hostname := func(component string) string {
+			return fmt.Sprintf("%s-%s-%s.%s", component, dask.Name, dask.Namespace, domain)
+		}
+		if dask.Spec.JupyterIngress == "" {
+			dask.Spec.JupyterIngress = hostname("notebook")
+		}
+		if dask.Spec.SchedulerIngress == "" {
+			dask.Spec.SchedulerIngress = hostname("scheduler")
+		}
+		if dask.Spec.MonitorIngress == "" {
+			dask.Spec.MonitorIngress = hostname("monitor")
+		}
+	}
+	if dask.Spec.Ingress == nil {
+		dask.Spec.Ingress = defaults.Ingress
+	}
+	if dask.Spec.Gateway != nil && dask.Spec.Gateway.From == nil {
+		dask.Spec.Gateway.From = defaults.GatewayFrom
+	}
+
+	return dask
+}
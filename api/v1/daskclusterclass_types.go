@@ -0,0 +1,105 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultClassAnnotation marks the DaskClusterClass that Dasks without a
+// className pick up
+const DefaultClassAnnotation = "analytics.piersharding.com/is-default-class"
+
+// DaskClusterClassSpec defines the defaults for the Dasks of a class - the
+// settings of a Dask take precedence over them
+type DaskClusterClassSpec struct {
+	// Source image to deploy clusters from - default: the IMAGE of the operator
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Pull Policy for image - default: the PULL_POLICY of the operator
+	// +optional
+	ImagePullPolicy string `json:"imagePullPolicy,omitempty"`
+
+	// Image for the Jupyter Notebook - default: jupyter/scipy-notebook:latest
+	// +optional
+	JupyterImage string `json:"jupyterImage,omitempty"`
+
+	// Specifies the Resources of all of the Pods.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Specifies the NodeSelector configuration.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Specifies the Toleration configuration.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// The domain that the Ingress hostnames of a Dask default to, as
+	// <notebook|scheduler|monitor>-<name>-<namespace>.<domain> - for the
+	// Dasks that set ingress: or gateway:
+	// +optional
+	IngressDomain string `json:"ingressDomain,omitempty"`
+
+	// The class, annotations and TLS of the Ingress
+	// +optional
+	Ingress *DaskIngressSpec `json:"ingress,omitempty"`
+
+	// The Pods of the Gateway implementation, that the NetworkPolicy lets
+	// through to the Scheduler dashboard of the Dasks that set gateway:
+	// +optional
+	GatewayFrom []networkingv1.NetworkPolicyPeer `json:"gatewayFrom,omitempty"`
+
+	// Disable Network Policies
+	// +optional
+	DisablePolicies bool `json:"disablepolicies,omitempty"`
+
+	// +kubebuilder:validation:Enum=default;restricted
+
+	// The Pod Security Standard that the Dasks run within - default: default
+	// +optional
+	SecurityProfile string `json:"securityProfile,omitempty"`
+}
+
+// DaskClusterClass is the Schema for the daskclusterclasses API
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.image",description="The default image of the class",priority=0
+// +kubebuilder:printcolumn:name="Domain",type="string",JSONPath=".spec.ingressDomain",description="The Ingress domain of the class",priority=0
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time since the DaskClusterClass was created",priority=0
+type DaskClusterClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DaskClusterClassSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DaskClusterClassList contains a list of DaskClusterClass
+type DaskClusterClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DaskClusterClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DaskClusterClass{}, &DaskClusterClassList{})
+}
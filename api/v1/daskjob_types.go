@@ -18,6 +18,7 @@ package v1
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -80,24 +81,91 @@ type DaskJobSpec struct {
 	// Specifies the Environment variables.
 	// +optional
 	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// A partial Pod template, strategically merged over the one generated
+	// for the job - eg: for init containers, sidecars or probes
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PodTemplate *runtime.RawExtension `json:"podTemplate,omitempty"`
+
+	// The PriorityClass of the job Pod
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Dask configuration that is merged over that of the cluster, key by key,
+	// for the job
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	DaskConfig *runtime.RawExtension `json:"daskConfig,omitempty"`
 }
 
+// Condition types reported on a DaskJob
+const (
+	// DaskJobClusterReady - the Dask cluster that the job runs against is Ready
+	DaskJobClusterReady = "ClusterReady"
+	// DaskJobComplete - the job has run to completion
+	DaskJobComplete = "Complete"
+	// DaskJobFailed - the job has failed
+	DaskJobFailed = "Failed"
+)
+
 // DaskJobStatus defines the observed state of DaskJob
 type DaskJobStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	Succeeded int32  `json:"succeeded"`
-	State     string `json:"state"`
-	Resources string `json:"resources"`
+
+	// The generation of the DaskJob last handled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Number of job Pods that succeeded
+	Succeeded int32 `json:"succeeded"`
+
+	// Number of job Pods that are running
+	// +optional
+	Active int32 `json:"active,omitempty"`
+
+	// Number of job Pods that failed
+	// +optional
+	Failed int32 `json:"failed,omitempty"`
+
+	// Summary of the job state: Pending, Running, Complete, Failed or Error
+	State string `json:"state"`
+
+	// Time the job started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Time the job completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// How long the job took to complete
+	// +optional
+	Duration string `json:"duration,omitempty"`
+
+	// Name of the PersistentVolumeClaim holding the job report
+	// +optional
+	ReportClaim string `json:"reportClaim,omitempty"`
+
+	// The latest observations of the job
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 // DaskJob is the Schema for the daskjobs API
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
-// +kubebuilder:printcolumn:name="Succeeded",type="integer",JSONPath=".status.succeeded",description="The number of Components Launched in the Dask",priority=0
-// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The number of Components Requested in the Dask",priority=0
-// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state",description="Status of the Dask",priority=0
-// +kubebuilder:printcolumn:name="Resources",type="string",JSONPath=".status.resources",description=" Resource details of the Dask",priority=1
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state",description="Status of the DaskJob",priority=0
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.cluster",description="The Dask cluster the job runs against",priority=0
+// +kubebuilder:printcolumn:name="Duration",type="string",JSONPath=".status.duration",description="How long the job took to complete",priority=0
+// +kubebuilder:printcolumn:name="Report",type="string",JSONPath=".status.reportClaim",description="The PersistentVolumeClaim holding the job report",priority=0
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time since the DaskJob was created",priority=0
+// +kubebuilder:printcolumn:name="Succeeded",type="integer",JSONPath=".status.succeeded",description="The number of job Pods that succeeded",priority=1
 type DaskJob struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -18,6 +18,9 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"net/http"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -25,18 +28,23 @@ import (
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	ctrl "sigs.k8s.io/controller-runtime"
-	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 var daskjoblog = logf.Log.WithName("daskjob-resource")
 
-// SetupWebhookWithManager - bootstrap manager
+// SetupWebhookWithManager registers the defaulting webhook, and the
+// validating webhook that reads the DaskPolicies through the client of the
+// manager
 func (r *DaskJob) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	daskjoblog.Info("Activating Webhook")
-	return ctrl.NewWebhookManagedBy(mgr).
-		For(r).
-		Complete()
+	server := mgr.GetWebhookServer()
+	server.Register("/mutate-analytics-piersharding-com-v1-daskjob", admission.DefaultingWebhookFor(r))
+	server.Register("/validate-analytics-piersharding-com-v1-daskjob", &webhook.Admission{Handler: &daskJobValidator{reader: mgr.GetClient()}})
+	return nil
 }
 
 // +kubebuilder:webhook:path=/mutate-analytics-piersharding-com-v1-daskjob,mutating=true,failurePolicy=fail,groups=analytics.piersharding.com,resources=daskjobs,verbs=create;update,versions=v1,name=mdaskjob.piersharding.com
@@ -62,18 +70,20 @@ func (r *DaskJob) Default() {
 
 var _ webhook.Validator = &DaskJob{}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+// ValidateCreate implements webhook.Validator - without the DaskPolicies,
+// which only the webhook can read
 func (r *DaskJob) ValidateCreate() error {
 	daskjoblog.Info("validate create", "name", r.Name)
 
-	return r.validateDaskJob()
+	return r.validateDaskJob(context.Background(), nil)
 }
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+// ValidateUpdate implements webhook.Validator - without the DaskPolicies,
+// which only the webhook can read
 func (r *DaskJob) ValidateUpdate(old runtime.Object) error {
 	daskjoblog.Info("validate update", "name", r.Name)
 
-	return r.validateDaskJob()
+	return r.validateDaskJob(context.Background(), nil)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -84,7 +94,32 @@ func (r *DaskJob) ValidateDelete() error {
 	return nil
 }
 
-func (r *DaskJob) validateDaskJob() error {
+// daskJobValidator is the validating webhook for DaskJobs, which holds them
+// to the DaskPolicies read through its reader as well
+type daskJobValidator struct {
+	reader  client.Reader
+	decoder *admission.Decoder
+}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *daskJobValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler
+func (v *daskJobValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	daskjob := &DaskJob{}
+	if err := v.decoder.Decode(req, daskjob); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	daskjoblog.Info("validate", "name", daskjob.Name, "operation", req.Operation)
+	return validationResponse(daskjob.validateDaskJob(ctx, v.reader))
+}
+
+// validateDaskJob checks a DaskJob, and against the DaskPolicies when there
+// is a reader for them
+func (r *DaskJob) validateDaskJob(ctx context.Context, reader client.Reader) error {
 	var allErrs field.ErrorList
 	if err := r.validateDaskJobName(); err != nil {
 		allErrs = append(allErrs, err)
@@ -92,6 +127,9 @@ func (r *DaskJob) validateDaskJob() error {
 	if err := r.validateDaskJobSpec(); err != nil {
 		allErrs = append(allErrs, err)
 	}
+	if reader != nil {
+		allErrs = append(allErrs, r.validateDaskJobPolicies(ctx, reader)...)
+	}
 	if len(allErrs) == 0 {
 		return nil
 	}
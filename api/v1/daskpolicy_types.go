@@ -0,0 +1,97 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DaskPolicySpec defines the limits on the Dasks and DaskJobs of the
+// namespaces that a policy selects
+type DaskPolicySpec struct {
+	// The namespaces the policy applies to - default: all of them
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+
+	// The most workers a Dask may have, counting its worker groups and the
+	// adaptive maximum
+	// +optional
+	MaxWorkersPerCluster *int32 `json:"maxWorkersPerCluster,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+
+	// The most workers of all of the Dasks in a namespace
+	// +optional
+	MaxWorkers *int32 `json:"maxWorkers,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+
+	// The most Dasks in a namespace
+	// +optional
+	MaxClusters *int32 `json:"maxClusters,omitempty"`
+
+	// Images that may be run, as patterns eg: daskdev/dask:* - when neither
+	// these nor allowedRegistries are given, any image may be run
+	// +optional
+	AllowedImages []string `json:"allowedImages,omitempty"`
+
+	// Registries that images may be run from eg: docker.io or
+	// registry.example.com:5000
+	// +optional
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+
+	// The most of each resource that a Pod may request, or be limited to
+	// +optional
+	MaxResources corev1.ResourceList `json:"maxResources,omitempty"`
+
+	// Dasks may run a Jupyter Notebook - default: true
+	// +optional
+	AllowJupyter *bool `json:"allowJupyter,omitempty"`
+
+	// Dasks may be exposed through an Ingress or HTTPRoutes - default: true
+	// +optional
+	AllowIngress *bool `json:"allowIngress,omitempty"`
+}
+
+// DaskPolicy is the Schema for the daskpolicies API
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Workers",type="integer",JSONPath=".spec.maxWorkersPerCluster",description="The most workers of a Dask",priority=0
+// +kubebuilder:printcolumn:name="Total",type="integer",JSONPath=".spec.maxWorkers",description="The most workers in a namespace",priority=0
+// +kubebuilder:printcolumn:name="Clusters",type="integer",JSONPath=".spec.maxClusters",description="The most Dasks in a namespace",priority=0
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time since the DaskPolicy was created",priority=0
+type DaskPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DaskPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DaskPolicyList contains a list of DaskPolicy
+type DaskPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DaskPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DaskPolicy{}, &DaskPolicyList{})
+}
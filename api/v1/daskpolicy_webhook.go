@@ -0,0 +1,264 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// PoliciesFor lists the DaskPolicies that select a namespace
+func PoliciesFor(ctx context.Context, c client.Reader, namespace string) ([]DaskPolicy, error) {
+	var policies DaskPolicyList
+	if err := c.List(ctx, &policies); err != nil {
+		return nil, err
+	}
+	if len(policies.Items) == 0 {
+		return nil, nil
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return nil, err
+	}
+	var out []DaskPolicy
+	for _, policy := range policies.Items {
+		selector := labels.Everything()
+		if policy.Spec.NamespaceSelector != nil {
+			var err error
+			if selector, err = metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector); err != nil {
+				return nil, err
+			}
+		}
+		if selector.Matches(labels.Set(ns.Labels)) {
+			out = append(out, policy)
+		}
+	}
+	return out, nil
+}
+
+// ValidateDask checks a Dask against the policy, along with the other Dasks
+// of its namespace - the number of clusters is only checked for a new Dask,
+// so that existing ones can still be changed
+func (p *DaskPolicy) ValidateDask(dask *Dask, others []Dask, create bool) field.ErrorList {
+	var allErrs field.ErrorList
+	spec := field.NewPath("spec")
+
+	workers := dask.workerCount()
+	if max := p.Spec.MaxWorkersPerCluster; max != nil && workers > *max {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("replicas"), fmt.Sprintf("%d workers is more than the %d per cluster allowed by DaskPolicy %s", workers, *max, p.Name)))
+	}
+	total, clusters := workers, int32(1)
+	for i := range others {
+		if others[i].Name != dask.Name {
+			total += others[i].workerCount()
+			clusters++
+		}
+	}
+	if max := p.Spec.MaxWorkers; max != nil && total > *max {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("replicas"), fmt.Sprintf("the namespace would have %d workers, more than the %d allowed by DaskPolicy %s", total, *max, p.Name)))
+	}
+	if max := p.Spec.MaxClusters; create && max != nil && clusters > *max {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("metadata").Child("namespace"), fmt.Sprintf("the namespace would have %d Dasks, more than the %d allowed by DaskPolicy %s", clusters, *max, p.Name)))
+	}
+
+	if err := p.ValidateImage(dask.Spec.Image, spec.Child("image")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	if p.Spec.AllowJupyter != nil && !*p.Spec.AllowJupyter && dask.Spec.Jupyter {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("jupyter"), fmt.Sprintf("a Jupyter Notebook is not allowed by DaskPolicy %s", p.Name)))
+	}
+	if p.Spec.AllowIngress != nil && !*p.Spec.AllowIngress {
+		for _, exposed := range []struct {
+			name string
+			set  bool
+		}{
+			{"jupyterIngress", dask.Spec.JupyterIngress != ""},
+			{"schedulerIngress", dask.Spec.SchedulerIngress != ""},
+			{"monitorIngress", dask.Spec.MonitorIngress != ""},
+			{"gateway", dask.Spec.Gateway != nil},
+		} {
+			if exposed.set {
+				allErrs = append(allErrs, field.Forbidden(spec.Child(exposed.name), fmt.Sprintf("exposing the cluster is not allowed by DaskPolicy %s", p.Name)))
+			}
+		}
+	}
+
+	allErrs = append(allErrs, p.validateResources(dask.Spec.Resources, spec.Child("resources"))...)
+	if dask.Spec.Scheduler != nil {
+		allErrs = append(allErrs, p.validateResources(dask.Spec.Scheduler.Resources, spec.Child("scheduler").Child("resources"))...)
+	}
+	if dask.Spec.Worker != nil {
+		allErrs = append(allErrs, p.validateResources(dask.Spec.Worker.Resources, spec.Child("worker").Child("resources"))...)
+	}
+	if dask.Spec.Notebook != nil {
+		allErrs = append(allErrs, p.validateResources(dask.Spec.Notebook.Resources, spec.Child("notebook").Child("resources"))...)
+	}
+	for i, group := range dask.Spec.WorkerGroups {
+		allErrs = append(allErrs, p.validateResources(group.Resources, spec.Child("workerGroups").Index(i).Child("resources"))...)
+	}
+	return allErrs
+}
+
+// ValidateDaskJob checks a DaskJob against the policy
+func (p *DaskPolicy) ValidateDaskJob(daskjob *DaskJob) field.ErrorList {
+	var allErrs field.ErrorList
+	spec := field.NewPath("spec")
+	if err := p.ValidateImage(daskjob.Spec.Image, spec.Child("image")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	return append(allErrs, p.validateResources(daskjob.Spec.Resources, spec.Child("resources"))...)
+}
+
+// ValidateImage checks that an image may be run under the policy - it must
+// match one of the allowed images, or come from one of the allowed
+// registries
+func (p *DaskPolicy) ValidateImage(image string, fldPath *field.Path) *field.Error {
+	if len(p.Spec.AllowedImages) == 0 && len(p.Spec.AllowedRegistries) == 0 {
+		return nil
+	}
+	if image == "" {
+		return field.Required(fldPath, fmt.Sprintf("an image allowed by DaskPolicy %s", p.Name))
+	}
+	for _, pattern := range p.Spec.AllowedImages {
+		if ok, _ := path.Match(pattern, image); ok {
+			return nil
+		}
+	}
+	registry := imageRegistry(image)
+	for _, allowed := range p.Spec.AllowedRegistries {
+		if registry == allowed {
+			return nil
+		}
+	}
+	return field.Forbidden(fldPath, fmt.Sprintf("image %s is not allowed by DaskPolicy %s", image, p.Name))
+}
+
+// validateResources checks that the requests and limits of a Pod are within
+// the maximum resources of the policy
+func (p *DaskPolicy) validateResources(resources *corev1.ResourceRequirements, fldPath *field.Path) field.ErrorList {
+	if resources == nil || len(p.Spec.MaxResources) == 0 {
+		return nil
+	}
+	var names []string
+	for name := range p.Spec.MaxResources {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	var allErrs field.ErrorList
+	for _, list := range []struct {
+		name      string
+		resources corev1.ResourceList
+	}{{"requests", resources.Requests}, {"limits", resources.Limits}} {
+		for _, name := range names {
+			max := p.Spec.MaxResources[corev1.ResourceName(name)]
+			if value, ok := list.resources[corev1.ResourceName(name)]; ok && value.Cmp(max) > 0 {
+				allErrs = append(allErrs, field.Forbidden(fldPath.Child(list.name).Key(name), fmt.Sprintf("%s is more than the %s allowed by DaskPolicy %s", value.String(), max.String(), p.Name)))
+			}
+		}
+	}
+	return allErrs
+}
+
+// imageRegistry gives the registry that an image is pulled from - docker.io
+// when the reference does not start with one
+func imageRegistry(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return "docker.io"
+	}
+	if first := image[:i]; strings.ContainsAny(first, ".:") || first == "localhost" {
+		return first
+	}
+	return "docker.io"
+}
+
+// validatePolicies checks a Dask against the DaskPolicies of its namespace,
+// as it will be run - with the defaults of its class, and then the image of
+// the operator, filled in
+func (v *daskValidator) validatePolicies(ctx context.Context, dask *Dask, create bool) field.ErrorList {
+	policies, err := PoliciesFor(ctx, v.reader, dask.Namespace)
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("metadata").Child("namespace"), err)}
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+	// a missing class is reported by the controller, and holds up the Dask
+	// until it is created - when the Dask is checked again
+	class, err := ClassFor(ctx, v.reader, dask)
+	var notFound ClassNotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return field.ErrorList{field.InternalError(field.NewPath("spec").Child("className"), err)}
+	}
+	merged := ApplyClass(*dask, class)
+	if merged.Spec.Image == "" {
+		merged.Spec.Image = v.defaultImage
+	}
+	var dasks DaskList
+	if err := v.reader.List(ctx, &dasks, client.InNamespace(dask.Namespace)); err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("metadata").Child("namespace"), err)}
+	}
+	var allErrs field.ErrorList
+	for i := range policies {
+		allErrs = append(allErrs, policies[i].ValidateDask(&merged, dasks.Items, create)...)
+	}
+	return allErrs
+}
+
+// validateDaskJobPolicies checks a DaskJob against the DaskPolicies of its
+// namespace
+func (r *DaskJob) validateDaskJobPolicies(ctx context.Context, reader client.Reader) field.ErrorList {
+	policies, err := PoliciesFor(ctx, reader, r.Namespace)
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("metadata").Child("namespace"), err)}
+	}
+	var allErrs field.ErrorList
+	for i := range policies {
+		allErrs = append(allErrs, policies[i].ValidateDaskJob(r)...)
+	}
+	return allErrs
+}
+
+// validationResponse gives the admission response for the outcome of a
+// validation, keeping the field errors of an Invalid error
+func validationResponse(err error) admission.Response {
+	if err == nil {
+		return admission.Allowed("")
+	}
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) {
+		status := apiStatus.Status()
+		return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &status,
+		}}
+	}
+	return admission.Denied(err.Error())
+}
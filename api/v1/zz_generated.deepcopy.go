@@ -21,7 +21,10 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -30,7 +33,7 @@ func (in *Dask) DeepCopyInto(out *Dask) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Dask.
@@ -51,6 +54,153 @@ func (in *Dask) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskAdaptiveSpec) DeepCopyInto(out *DaskAdaptiveSpec) {
+	*out = *in
+	if in.Cooldown != nil {
+		in, out := &in.Cooldown, &out.Cooldown
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskAdaptiveSpec.
+func (in *DaskAdaptiveSpec) DeepCopy() *DaskAdaptiveSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskAdaptiveSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskAdaptiveStatus) DeepCopyInto(out *DaskAdaptiveStatus) {
+	*out = *in
+	if in.LastScaleTime != nil {
+		in, out := &in.LastScaleTime, &out.LastScaleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ScaleDownSince != nil {
+		in, out := &in.ScaleDownSince, &out.ScaleDownSince
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskAdaptiveStatus.
+func (in *DaskAdaptiveStatus) DeepCopy() *DaskAdaptiveStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskAdaptiveStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskClusterClass) DeepCopyInto(out *DaskClusterClass) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskClusterClass.
+func (in *DaskClusterClass) DeepCopy() *DaskClusterClass {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskClusterClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DaskClusterClass) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskClusterClassList) DeepCopyInto(out *DaskClusterClassList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DaskClusterClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskClusterClassList.
+func (in *DaskClusterClassList) DeepCopy() *DaskClusterClassList {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskClusterClassList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DaskClusterClassList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskClusterClassSpec) DeepCopyInto(out *DaskClusterClassSpec) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(DaskIngressSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GatewayFrom != nil {
+		in, out := &in.GatewayFrom, &out.GatewayFrom
+		*out = make([]networkingv1.NetworkPolicyPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskClusterClassSpec.
+func (in *DaskClusterClassSpec) DeepCopy() *DaskClusterClassSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskClusterClassSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DaskDeploymentSpec) DeepCopyInto(out *DaskDeploymentSpec) {
 	*out = *in
@@ -104,6 +254,48 @@ func (in *DaskDeploymentSpec) DeepCopyInto(out *DaskDeploymentSpec) {
 		*out = new(corev1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DaskConfig != nil {
+		in, out := &in.DaskConfig, &out.DaskConfig
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DaskResources != nil {
+		in, out := &in.DaskResources, &out.DaskResources
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NThreads != nil {
+		in, out := &in.NThreads, &out.NThreads
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NWorkers != nil {
+		in, out := &in.NWorkers, &out.NWorkers
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartScript != nil {
+		in, out := &in.StartScript, &out.StartScript
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskDeploymentSpec.
@@ -116,13 +308,115 @@ func (in *DaskDeploymentSpec) DeepCopy() *DaskDeploymentSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskDisruptionBudgetSpec) DeepCopyInto(out *DaskDisruptionBudgetSpec) {
+	*out = *in
+	if in.Scheduler != nil {
+		in, out := &in.Scheduler, &out.Scheduler
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskDisruptionBudgetSpec.
+func (in *DaskDisruptionBudgetSpec) DeepCopy() *DaskDisruptionBudgetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskDisruptionBudgetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskGatewayParentRef) DeepCopyInto(out *DaskGatewayParentRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskGatewayParentRef.
+func (in *DaskGatewayParentRef) DeepCopy() *DaskGatewayParentRef {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskGatewayParentRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskGatewaySpec) DeepCopyInto(out *DaskGatewaySpec) {
+	*out = *in
+	out.ParentRef = in.ParentRef
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]networkingv1.NetworkPolicyPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskGatewaySpec.
+func (in *DaskGatewaySpec) DeepCopy() *DaskGatewaySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskGatewaySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskIngressSpec) DeepCopyInto(out *DaskIngressSpec) {
+	*out = *in
+	if in.ClassName != nil {
+		in, out := &in.ClassName, &out.ClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = make([]networkingv1.IngressTLS, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskIngressSpec.
+func (in *DaskIngressSpec) DeepCopy() *DaskIngressSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskIngressSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DaskJob) DeepCopyInto(out *DaskJob) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskJob.
@@ -228,6 +522,16 @@ func (in *DaskJobSpec) DeepCopyInto(out *DaskJobSpec) {
 		*out = new(corev1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DaskConfig != nil {
+		in, out := &in.DaskConfig, &out.DaskConfig
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskJobSpec.
@@ -243,6 +547,21 @@ func (in *DaskJobSpec) DeepCopy() *DaskJobSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DaskJobStatus) DeepCopyInto(out *DaskJobStatus) {
 	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskJobStatus.
@@ -255,6 +574,26 @@ func (in *DaskJobStatus) DeepCopy() *DaskJobStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskJupyterAuthSpec) DeepCopyInto(out *DaskJupyterAuthSpec) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskJupyterAuthSpec.
+func (in *DaskJupyterAuthSpec) DeepCopy() *DaskJupyterAuthSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskJupyterAuthSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DaskList) DeepCopyInto(out *DaskList) {
 	*out = *in
@@ -287,9 +626,254 @@ func (in *DaskList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskNotebookSpec) DeepCopyInto(out *DaskNotebookSpec) {
+	*out = *in
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(DaskServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.DaskDeploymentSpec.DeepCopyInto(&out.DaskDeploymentSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskNotebookSpec.
+func (in *DaskNotebookSpec) DeepCopy() *DaskNotebookSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskNotebookSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskPolicy) DeepCopyInto(out *DaskPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskPolicy.
+func (in *DaskPolicy) DeepCopy() *DaskPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DaskPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskPolicyList) DeepCopyInto(out *DaskPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DaskPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskPolicyList.
+func (in *DaskPolicyList) DeepCopy() *DaskPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DaskPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskPolicySpec) DeepCopyInto(out *DaskPolicySpec) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxWorkersPerCluster != nil {
+		in, out := &in.MaxWorkersPerCluster, &out.MaxWorkersPerCluster
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxWorkers != nil {
+		in, out := &in.MaxWorkers, &out.MaxWorkers
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxClusters != nil {
+		in, out := &in.MaxClusters, &out.MaxClusters
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AllowedImages != nil {
+		in, out := &in.AllowedImages, &out.AllowedImages
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedRegistries != nil {
+		in, out := &in.AllowedRegistries, &out.AllowedRegistries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxResources != nil {
+		in, out := &in.MaxResources, &out.MaxResources
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.AllowJupyter != nil {
+		in, out := &in.AllowJupyter, &out.AllowJupyter
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowIngress != nil {
+		in, out := &in.AllowIngress, &out.AllowIngress
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskPolicySpec.
+func (in *DaskPolicySpec) DeepCopy() *DaskPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskSchedulerSpec) DeepCopyInto(out *DaskSchedulerSpec) {
+	*out = *in
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(DaskServiceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.DaskDeploymentSpec.DeepCopyInto(&out.DaskDeploymentSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskSchedulerSpec.
+func (in *DaskSchedulerSpec) DeepCopy() *DaskSchedulerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskSchedulerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskServiceSpec) DeepCopyInto(out *DaskServiceSpec) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodePorts != nil {
+		in, out := &in.NodePorts, &out.NodePorts
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskServiceSpec.
+func (in *DaskServiceSpec) DeepCopy() *DaskServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DaskSpec) DeepCopyInto(out *DaskSpec) {
 	*out = *in
+	if in.DisablePolicies != nil {
+		in, out := &in.DisablePolicies, &out.DisablePolicies
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Adaptive != nil {
+		in, out := &in.Adaptive, &out.Adaptive
+		*out = new(DaskAdaptiveSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IdleTimeout != nil {
+		in, out := &in.IdleTimeout, &out.IdleTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.TTLSecondsAfterCreation != nil {
+		in, out := &in.TTLSecondsAfterCreation, &out.TTLSecondsAfterCreation
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiryWarnings != nil {
+		in, out := &in.ExpiryWarnings, &out.ExpiryWarnings
+		*out = make([]metav1.Duration, len(*in))
+		copy(*out, *in)
+	}
+	if in.JupyterAuth != nil {
+		in, out := &in.JupyterAuth, &out.JupyterAuth
+		*out = new(DaskJupyterAuthSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(DaskIngressSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		*out = new(DaskGatewaySpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]corev1.Volume, len(*in))
@@ -342,17 +926,39 @@ func (in *DaskSpec) DeepCopyInto(out *DaskSpec) {
 	}
 	if in.Scheduler != nil {
 		in, out := &in.Scheduler, &out.Scheduler
-		*out = new(DaskDeploymentSpec)
+		*out = new(DaskSchedulerSpec)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.Worker != nil {
 		in, out := &in.Worker, &out.Worker
-		*out = new(DaskDeploymentSpec)
+		*out = new(DaskWorkerSpec)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.Notebook != nil {
 		in, out := &in.Notebook, &out.Notebook
-		*out = new(DaskDeploymentSpec)
+		*out = new(DaskNotebookSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WorkerGroups != nil {
+		in, out := &in.WorkerGroups, &out.WorkerGroups
+		*out = make([]DaskWorkerGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(DaskTLSSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DisruptionBudget != nil {
+		in, out := &in.DisruptionBudget, &out.DisruptionBudget
+		*out = new(DaskDisruptionBudgetSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DaskConfig != nil {
+		in, out := &in.DaskConfig, &out.DaskConfig
+		*out = new(runtime.RawExtension)
 		(*in).DeepCopyInto(*out)
 	}
 }
@@ -370,6 +976,40 @@ func (in *DaskSpec) DeepCopy() *DaskSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DaskStatus) DeepCopyInto(out *DaskStatus) {
 	*out = *in
+	if in.Adaptive != nil {
+		in, out := &in.Adaptive, &out.Adaptive
+		*out = new(DaskAdaptiveStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IdleSince != nil {
+		in, out := &in.IdleSince, &out.IdleSince
+		*out = (*in).DeepCopy()
+	}
+	if in.CertificatesNotAfter != nil {
+		in, out := &in.CertificatesNotAfter, &out.CertificatesNotAfter
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.LeaseExtension != nil {
+		in, out := &in.LeaseExtension, &out.LeaseExtension
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ExpiryWarned != nil {
+		in, out := &in.ExpiryWarned, &out.ExpiryWarned
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskStatus.
@@ -381,3 +1021,95 @@ func (in *DaskStatus) DeepCopy() *DaskStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskTLSSpec) DeepCopyInto(out *DaskTLSSpec) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RenewBefore != nil {
+		in, out := &in.RenewBefore, &out.RenewBefore
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskTLSSpec.
+func (in *DaskTLSSpec) DeepCopy() *DaskTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskWorkerGroup) DeepCopyInto(out *DaskWorkerGroup) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.DaskDeploymentSpec.DeepCopyInto(&out.DaskDeploymentSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskWorkerGroup.
+func (in *DaskWorkerGroup) DeepCopy() *DaskWorkerGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskWorkerGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskWorkerSpec) DeepCopyInto(out *DaskWorkerSpec) {
+	*out = *in
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(DaskWorkerStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.DaskDeploymentSpec.DeepCopyInto(&out.DaskDeploymentSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskWorkerSpec.
+func (in *DaskWorkerSpec) DeepCopy() *DaskWorkerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskWorkerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaskWorkerStorageSpec) DeepCopyInto(out *DaskWorkerStorageSpec) {
+	*out = *in
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaskWorkerStorageSpec.
+func (in *DaskWorkerStorageSpec) DeepCopy() *DaskWorkerStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DaskWorkerStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	"gitlab.com/piersharding/dask-operator/scheduler"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// schedulerPollInterval is how often the Scheduler load is checked, for
+// adaptive scaling and idle hibernation
+var schedulerPollInterval = 15 * time.Second
+
+// defaultAdaptiveCooldown is the minimum time between scaling actions
+const defaultAdaptiveCooldown = time.Minute
+
+// schedulerAddress gives the URL of the Scheduler monitor (bokeh) endpoint
+func (r *DaskReconciler) schedulerAddress(dcontext dtypes.DaskContext) string {
+	if r.SchedulerAddress != nil {
+		return r.SchedulerAddress(dcontext)
+	}
+	return fmt.Sprintf("http://dask-scheduler-%s.%s:%d", dcontext.Name, dcontext.Namespace, dcontext.BokehPort)
+}
+
+// clampReplicas keeps a worker count within the adaptive bounds
+func clampReplicas(replicas int32, spec *analyticsv1.DaskAdaptiveSpec) int32 {
+	if replicas < spec.Minimum {
+		return spec.Minimum
+	}
+	if replicas > spec.Maximum {
+		return spec.Maximum
+	}
+	return replicas
+}
+
+// adaptiveReplicas polls the Scheduler load and works out how many workers
+// an adaptive cluster should have, recording the decision in the status.
+// Scaling up waits only on the cooldown since the last scaling action, but
+// scaling down also needs the load to have stayed low for the cooldown.
+// When the Scheduler cannot be reached the workers are left as they are.
+func (r *DaskReconciler) adaptiveReplicas(dask *analyticsv1.Dask, dcontext dtypes.DaskContext, log logr.Logger) int32 {
+	spec := dask.Spec.Adaptive
+	status := dask.Status.Adaptive
+	if status == nil {
+		status = &analyticsv1.DaskAdaptiveStatus{Replicas: spec.Minimum, Target: spec.Minimum}
+		dask.Status.Adaptive = status
+	}
+	cooldown := defaultAdaptiveCooldown
+	if spec.Cooldown != nil {
+		cooldown = spec.Cooldown.Duration
+	}
+	now := metav1.Now()
+
+	// the bounds may have changed since the last pass
+	previous := status.Replicas
+	current := clampReplicas(previous, spec)
+
+	load, err := scheduler.GetLoad(r.schedulerAddress(dcontext))
+	if err != nil {
+		Infof(log, "Scheduler load unavailable, holding at %d workers: %s", current, err.Error())
+	} else {
+		target := clampReplicas(load.Target(), spec)
+		status.Target = target
+		cooled := status.LastScaleTime == nil || now.Sub(status.LastScaleTime.Time) >= cooldown
+		switch {
+		case target > current:
+			status.ScaleDownSince = nil
+			if cooled {
+				current = target
+			}
+		case target < current:
+			if status.ScaleDownSince == nil {
+				status.ScaleDownSince = &now
+			}
+			if cooled && now.Sub(status.ScaleDownSince.Time) >= cooldown {
+				current = target
+				status.ScaleDownSince = nil
+			}
+		default:
+			status.ScaleDownSince = nil
+		}
+	}
+
+	if current != previous {
+		Infof(log, "Adaptive scaling workers from %d to %d", previous, current)
+		r.Recorder.Eventf(dask, corev1.EventTypeNormal, "Scaled", "Scaled workers from %d to %d (target %d)", previous, current, status.Target)
+		status.LastScaleTime = &now
+	}
+	status.Replicas = current
+	return current
+}
@@ -0,0 +1,109 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+)
+
+var _ = Context("Inside of a new namespace with a stub Scheduler", func() {
+	ctx := context.TODO()
+	ns := SetupTest(ctx)
+
+	var stub *httptest.Server
+	var desired int32
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&desired, 0)
+		stub = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/json/counts.json":
+				// a backlog of one task for each worker wanted
+				backlog := atomic.LoadInt32(&desired)
+				fmt.Fprintf(w, `{"bytes": 0, "clients": 2, "cores": 1, "erred": 0, "hosts": 1, "idle": 0, "memory": 0, "processing": 0, "released": 0, "saturated": 1, "tasks": %d, "unrunnable": 0, "waiting": %d, "waiting_data": 0, "workers": 1}`, backlog, backlog)
+			case "/json/identity.json":
+				fmt.Fprint(w, `{"type": "Scheduler", "id": "Scheduler-stub", "address": "tcp://10.244.0.12:8786", "services": {"dashboard": 8787}, "workers": {
+					"tcp://10.244.0.13:38213": {"type": "Worker", "id": "worker-stub", "host": "10.244.0.13", "name": "tcp://10.244.0.13:38213", "nthreads": 1, "memory_limit": 4294967296,
+						"metrics": {"executing": 0, "in_memory": 0, "ready": 0, "in_flight": 0, "cpu": 2.0, "memory": 104857600, "time": 1666000042.4}}}}`)
+			default:
+				http.NotFound(w, req)
+			}
+		}))
+		schedulerURL = stub.URL
+	})
+
+	AfterEach(func() {
+		stub.Close()
+	})
+
+	Describe("when adaptive scaling is enabled", func() {
+
+		It("should scale the workers on the Scheduler load within the bounds", func() {
+			name := resource_name + "adaptive"
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Adaptive: &analyticsv1.DaskAdaptiveSpec{
+						Minimum:  1,
+						Maximum:  6,
+						Cooldown: &metav1.Duration{Duration: time.Second},
+					},
+				},
+			}
+			atomic.StoreInt32(&desired, 4)
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			workerKey := client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*10, time.Millisecond*500).
+				Should(Equal(int32(4)), "expected workers to scale to the task backlog")
+
+			atomic.StoreInt32(&desired, 20)
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*10, time.Millisecond*500).
+				Should(Equal(int32(6)), "expected workers to be held at the maximum")
+
+			atomic.StoreInt32(&desired, 0)
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*10, time.Millisecond*500).
+				Should(Equal(int32(1)), "expected workers to be held at the minimum")
+
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
+			Expect(dask.Status.Adaptive).NotTo(BeNil())
+			Expect(dask.Status.Adaptive.Target).To(Equal(int32(1)))
+		})
+	})
+})
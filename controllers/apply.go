@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"gitlab.com/piersharding/dask-operator/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// lastAppliedAnnotation records the state that the controller last applied
+// to a child resource, so that fields dropped from the spec can be removed
+const lastAppliedAnnotation = "analytics.piersharding.com/last-applied-configuration"
+
+// ownedState picks out the parts of a rendered resource that the controller
+// manages - the labels, annotations and everything outside of metadata and status
+func ownedState(obj map[string]interface{}) map[string]interface{} {
+	owned := map[string]interface{}{}
+	for k, v := range obj {
+		switch k {
+		case "apiVersion", "kind", "metadata", "status":
+		default:
+			owned[k] = v
+		}
+	}
+	metadata := map[string]interface{}{}
+	if m, ok := obj["metadata"].(map[string]interface{}); ok {
+		for _, k := range []string{"labels", "annotations"} {
+			if v, ok := m[k]; ok {
+				metadata[k] = v
+			}
+		}
+	}
+	owned["metadata"] = metadata
+	return owned
+}
+
+// toMap washes an API object through JSON to get the generic form
+func toMap(obj client.Object) (map[string]interface{}, error) {
+	byt, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	if err := json.Unmarshal(byt, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// newObject gives an empty object of the same type - unstructured objects
+// carry their kind with them
+func newObject(obj client.Object) client.Object {
+	out := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+	if u, ok := out.(*unstructured.Unstructured); ok {
+		u.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	}
+	return out
+}
+
+// applyResource creates a child resource, or brings an existing one back in
+// line with the desired state rendered by the models package.  It reports
+// whether the resource was created, and the paths of any fields changed.
+func applyResource(ctx context.Context, c client.Client, s *runtime.Scheme, owner metav1.Object, desired client.Object, log logr.Logger) (bool, []string, error) {
+
+	dmeta, err := meta.Accessor(desired)
+	if err != nil {
+		return false, nil, err
+	}
+	if err := ctrl.SetControllerReference(owner, dmeta, s); err != nil {
+		Errorf(log, err, "SetControllerReference Error: %+v\n", err)
+		return false, nil, err
+	}
+
+	want, err := toMap(desired)
+	if err != nil {
+		return false, nil, err
+	}
+	wantOwned := ownedState(want)
+	lastApplied, err := json.Marshal(wantOwned)
+	if err != nil {
+		return false, nil, err
+	}
+	annotations := dmeta.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[lastAppliedAnnotation] = string(lastApplied)
+	dmeta.SetAnnotations(annotations)
+
+	current := newObject(desired)
+	objkey := client.ObjectKey{Namespace: dmeta.GetNamespace(), Name: dmeta.GetName()}
+	if err := c.Get(ctx, objkey, current); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, nil, err
+		}
+		Debugf(log, "Creating %s: %+v", objkey, desired)
+		if err := c.Create(ctx, desired); err != nil {
+			return false, nil, err
+		}
+		return true, nil, nil
+	}
+
+	have, err := toMap(current)
+	if err != nil {
+		return false, nil, err
+	}
+	var last map[string]interface{}
+	if cmeta, err := meta.Accessor(current); err == nil {
+		if prev, ok := cmeta.GetAnnotations()[lastAppliedAnnotation]; ok {
+			if err := json.Unmarshal([]byte(prev), &last); err != nil {
+				Infof(log, "ignoring unreadable %s on %s: %s", lastAppliedAnnotation, objkey, err.Error())
+				last = nil
+			}
+		}
+	}
+
+	changed := utils.DiffPaths(wantOwned, have, "")
+	changed = append(changed, utils.RemovedPaths(last, wantOwned, have, "")...)
+	if len(changed) == 0 {
+		return false, nil, nil
+	}
+	Debugf(log, "Drift on %s: %v", objkey, changed)
+
+	merged := utils.MergeDesired(have, last, wantOwned)
+	byt, err := json.Marshal(merged)
+	if err != nil {
+		return false, nil, err
+	}
+	updated := newObject(desired)
+	if err := json.Unmarshal(byt, updated); err != nil {
+		return false, nil, err
+	}
+	umeta, err := meta.Accessor(updated)
+	if err != nil {
+		return false, nil, err
+	}
+	annotations = umeta.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[lastAppliedAnnotation] = string(lastApplied)
+	umeta.SetAnnotations(annotations)
+	if err := c.Update(ctx, updated); err != nil {
+		return false, changed, err
+	}
+	return false, changed, nil
+}
+
+// removeResource deletes a child resource that is no longer wanted, as long
+// as it is controlled by the owner. It reports whether anything was deleted.
+func removeResource(ctx context.Context, c client.Client, owner metav1.Object, unwanted client.Object) (bool, error) {
+	umeta, err := meta.Accessor(unwanted)
+	if err != nil {
+		return false, err
+	}
+	current := newObject(unwanted)
+	objkey := client.ObjectKey{Namespace: umeta.GetNamespace(), Name: umeta.GetName()}
+	if err := c.Get(ctx, objkey, current); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	cmeta, err := meta.Accessor(current)
+	if err != nil {
+		return false, err
+	}
+	if ref := metav1.GetControllerOf(cmeta); ref == nil || ref.UID != owner.GetUID() {
+		return false, nil
+	}
+	if err := c.Delete(ctx, current); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"context"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// classDasks maps a change to a DaskClusterClass on to the Dasks that take
+// their defaults from it
+func (r *DaskReconciler) classDasks(object client.Object) []reconcile.Request {
+	ctx := context.Background()
+	var dasks analyticsv1.DaskList
+	if err := r.List(ctx, &dasks); err != nil {
+		Errorf(r.Log, err, "unable to list the Dasks of DaskClusterClass %s", object.GetName())
+		return nil
+	}
+	isDefault := object.GetAnnotations()[analyticsv1.DefaultClassAnnotation] == "true"
+	var requests []reconcile.Request
+	for _, dask := range dasks.Items {
+		if dask.Spec.ClassName == object.GetName() || (dask.Spec.ClassName == "" && isDefault) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Namespace: dask.Namespace, Name: dask.Name}})
+		}
+	}
+	return requests
+}
@@ -16,18 +16,26 @@ limitations under the License.
 package controllers
 
 import (
-	"fmt"
-
 	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
 	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
@@ -39,17 +47,29 @@ import (
 var (
 	daskOwnerKey = ".metadata.daskcontroller"
 	daskApiGVStr = analyticsv1.GroupVersion.String()
+
+	// workerGroupLabel marks the Deployments of named worker groups
+	workerGroupLabel = "analytics.piersharding.com/worker-group"
 )
 
 // +kubebuilder:rbac:groups=analytics.piersharding.com,resources=dasks,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=analytics.piersharding.com,resources=dasks/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=analytics.piersharding.com,resources=dasks/finalizers,verbs=update
+// +kubebuilder:rbac:groups=analytics.piersharding.com,resources=daskclusterclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=analytics.piersharding.com,resources=daskpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=deployments/status,verbs=get
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=statefulsets/status,verbs=get
 // +kubebuilder:rbac:groups=core,resources=configmaps;secrets;services;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=services/status,verbs=get
-// +kubebuilder:rbac:groups=extensions,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=extensions,resources=ingresses/status,verbs=get
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses/status,verbs=get
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
 
 // DaskReconciler reconciles a Dask object
 type DaskReconciler struct {
@@ -58,11 +78,30 @@ type DaskReconciler struct {
 	CustomLog dtypes.CustomLogger
 	Scheme    *runtime.Scheme
 	Recorder  record.EventRecorder
+
+	// SchedulerAddress overrides how the Scheduler monitor endpoint is
+	// found for adaptive scaling - default: the Scheduler Service
+	SchedulerAddress func(dtypes.DaskContext) string
+
+	// ingressV1beta1 is set where the API server does not have
+	// networking.k8s.io/v1 Ingresses (before 1.19)
+	ingressV1beta1 bool
+
+	// pdbV1beta1 is set where the API server does not have policy/v1
+	// PodDisruptionBudgets (before 1.21)
+	pdbV1beta1 bool
+
+	// gatewayAPIVersion is the HTTPRoute version that the API server has -
+	// empty when the Gateway API is not installed
+	gatewayAPIVersion string
+
+	// retiring holds when the Scheduler was asked to retire each worker,
+	// for the retirements that were still going on at the timeout
+	retiring sync.Map
 }
 
 // Reconcile main reconcile loop
-func (r *DaskReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	ctx := context.Background()
+func (r *DaskReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("dask", req.NamespacedName)
 	clog := r.CustomLog.WithValues("dask", req.NamespacedName)
 	_ = clog
@@ -76,15 +115,27 @@ func (r *DaskReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	var currentSchedulerDeployment *appsv1.Deployment
-	var currentWorkerDeployment *appsv1.Deployment
-	var currentJupyterDeployment *appsv1.Deployment
-	var currentIngress *extv1beta1.Ingress
-	var currentConfig *corev1.ConfigMap
+	// retire the workers before the cluster is torn down
+	if !dask.DeletionTimestamp.IsZero() {
+		return r.finalizeDask(ctx, &dask, log)
+	}
+	if !controllerutil.ContainsFinalizer(&dask, daskFinalizer) {
+		controllerutil.AddFinalizer(&dask, daskFinalizer)
+		if err := r.Update(ctx, &dask); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// clusters with a time-to-live delete themselves
+	expired, untilExpiry, err := r.expiry(ctx, &dask, log)
+	if err != nil {
+		log.Error(err, "unable to check the Dask expiry")
+		return ctrl.Result{}, err
+	}
+	if expired {
+		return ctrl.Result{}, nil
+	}
 
-	dask.Status.Replicas = 0
-	dask.Status.Succeeded = 0
-	dask.Status.Resources = ""
 	dask.Status.State = "Building"
 
 	var childDeployments appsv1.DeploymentList
@@ -94,329 +145,169 @@ func (r *DaskReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			return ctrl.Result{}, err
 		}
 	}
-
-	currentSchedulerDeployment, _ = r.getDeployment(dask.Namespace, "dask-scheduler-"+dask.Name, &dask)
-	currentWorkerDeployment, _ = r.getDeployment(dask.Namespace, "dask-worker-"+dask.Name, &dask)
-	currentJupyterDeployment, _ = r.getDeployment(dask.Namespace, "jupyter-notebook-"+dask.Name, &dask)
-	currentIngress, _ = r.getIngress(dask.Namespace, "dask-"+dask.Name)
-	currentConfig, _ = r.getConfig(dask.Namespace, "dask-configs-"+dask.Name)
-
-	// Compute status based on latest observed state.
-	if dask.Status.Replicas == dask.Status.Succeeded {
-		dask.Status.State = "Running"
+	var childStatefulSets appsv1.StatefulSetList
+	if err := r.List(ctx, &childStatefulSets, client.InNamespace(req.Namespace), client.MatchingFields{daskOwnerKey: req.Name}); err != nil {
+		log.Error(err, "unable to list child StatefulSets")
+		if client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	var workerSets []client.Object
+	for i := range childDeployments.Items {
+		workerSets = append(workerSets, &childDeployments.Items[i])
+	}
+	for i := range childStatefulSets.Items {
+		workerSets = append(workerSets, &childStatefulSets.Items[i])
 	}
-	Infof(log, "Status replicas: %d, succeeded: %d", dask.Status.Replicas, dask.Status.Succeeded)
 
 	Debugf(log, "incoming context: %+v", dask)
 
-	// setup configuration.
-	dcontext := dtypes.SetConfig(dask)
-	if dcontext.Image == "" {
-		dcontext.Image = dtypes.Image
-	}
-	if dcontext.PullPolicy == "" {
-		dcontext.PullPolicy = dtypes.PullPolicy
+	// setup configuration, over the defaults of the class of the Dask
+	class, err := analyticsv1.ClassFor(ctx, r, &dask)
+	if err != nil {
+		log.Error(err, "unable to find the DaskClusterClass")
+		return r.reconcileFailed(ctx, &dask, "ClassNotFound", err)
 	}
+	dcontext := dtypes.SetClassConfig(dask, class)
 
-	// Get resource details
-	resources, err := r.resourceDetails(dcontext)
+	// certificates for TLS - a reissue rolls the Pods on to the new ones
+	untilRenewal, err := r.reconcileTLS(ctx, &dask, &dcontext, log)
 	if err != nil {
-		dask.Status.State = resources
-		return ctrl.Result{}, err
+		log.Error(err, "unable to issue TLS certificates")
+		return r.reconcileFailed(ctx, &dask, "CertificatesFailed", err)
 	}
-	dask.Status.Resources = resources
-
-	// Generate desired children.
-
-	// create dependent ConfigMap
-	Debugf(log, "###### Create ConfigMap #######")
-	if currentConfig == nil {
 
-		// create cluster wide DNS NetworkPolicy
-		if !dcontext.DisablePolicies {
-			dnsNetworkPolicy, err := models.DNSNetworkPolicy(dcontext)
-			if err != nil {
-				Errorf(log, err, "DNSNetworkPolicy Error: %+v\n", err)
-				dask.Status.State = fmt.Sprintf("DNSNetworkPolicy Error: %+v\n", err)
-				return ctrl.Result{}, err
-			}
-			dnsNetworkPolicy.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-			Debugf(log, "DNSNetworkPolicy: %+v", *dnsNetworkPolicy)
-			// set the reference
-			if err := ctrl.SetControllerReference(&dask, dnsNetworkPolicy, r.Scheme); err != nil {
-				Errorf(log, err, "DNSNetworkPolicy Error: %+v\n", err)
-				return ctrl.Result{}, err
-			}
-			// ...and create it on the cluster
-			if err := r.Create(ctx, dnsNetworkPolicy); err != nil {
-				log.Error(err, "unable to create DNSNetworkPolicy for Dask", "NetworkPolicy", dnsNetworkPolicy)
-				return ctrl.Result{}, err
-			}
-		}
+	// the Jupyter Notebook credentials are only ever held in a Secret
+	if err := r.reconcileJupyterAuth(ctx, &dask, &dcontext, log); err != nil {
+		log.Error(err, "unable to set up the Jupyter auth Secret")
+		return r.reconcileFailed(ctx, &dask, "JupyterAuthFailed", err)
+	}
 
-		// create cluster wide ServiceAccount
-		clusterServiceAccount, err := models.ClusterServiceAccount(dcontext)
-		if err != nil {
-			Errorf(log, err, "ClusterServiceAccount Error: %+v\n", err)
-			dask.Status.State = fmt.Sprintf("ClusterServiceAccount Error: %+v\n", err)
-			return ctrl.Result{}, err
-		}
-		clusterServiceAccount.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-		Debugf(log, "ClusterServiceAccount: %+v", *clusterServiceAccount)
-		// set the reference
-		if err := ctrl.SetControllerReference(&dask, clusterServiceAccount, r.Scheme); err != nil {
-			Errorf(log, err, "ClusterServiceAccount Error: %+v\n", err)
-			return ctrl.Result{}, err
-		}
-		// ...and create it on the cluster
-		if err := r.Create(ctx, clusterServiceAccount); err != nil {
-			log.Error(err, "unable to create ClusterServiceAccount for Dask", "ServiceAccount", clusterServiceAccount)
-			return ctrl.Result{}, err
+	// suspended clusters keep their configuration, but run nothing
+	suspended := dask.Spec.Suspend
+	if suspended != meta.IsStatusConditionTrue(dask.Status.Conditions, analyticsv1.DaskSuspended) {
+		if suspended {
+			r.Recorder.Event(&dask, corev1.EventTypeNormal, "Suspended", "Suspending the cluster")
+		} else {
+			r.Recorder.Event(&dask, corev1.EventTypeNormal, "Resumed", "Resuming the cluster")
 		}
+	}
+	dcontext.Suspended = suspended
 
-		// create ConfigMap
-		configMap, err := models.DaskConfigs(dcontext)
+	// idle clusters are hibernated until they are woken up - there is no
+	// Scheduler to ask while suspended
+	hibernated := meta.IsStatusConditionTrue(dask.Status.Conditions, analyticsv1.DaskHibernated)
+	if !suspended {
+		hibernated, err = r.hibernation(ctx, &dask, dcontext, log)
 		if err != nil {
-			Errorf(log, err, "DaskConfigs Error: %+v\n", err)
-			dask.Status.State = fmt.Sprintf("DaskConfigs Error: %+v\n", err)
-			return ctrl.Result{}, err
-		}
-		configMap.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-
-		Debugf(log, "DaskConfigs: %+v", *configMap)
-		// set the reference
-		if err := ctrl.SetControllerReference(&dask, configMap, r.Scheme); err != nil {
-			Errorf(log, err, "DaskConfigs Error: %+v\n", err)
-			return ctrl.Result{}, err
-		}
-		// ...and create it on the cluster
-		if err := r.Create(ctx, configMap); err != nil {
-			log.Error(err, "unable to create ConfigMap for Dask", "configMap", configMap)
-			return ctrl.Result{}, err
+			log.Error(err, "unable to wake up Dask")
+			return r.reconcileFailed(ctx, &dask, "WakeUpFailed", err)
 		}
 	}
+	dcontext.Hibernated = hibernated
 
-	// if we have Jupyter enabled, create deployment
-	Debugf(log, "###### Create Jupyter #######")
-	if dcontext.Jupyter && currentJupyterDeployment == nil {
-
-		// create NetworkPolicy
-		if !dcontext.DisablePolicies {
-			jupyterNetworkPolicy, err := models.JupyterNetworkPolicy(dcontext.ForNotebook())
-			if err != nil {
-				Errorf(log, err, "JupyterNetworkPolicy Error: %+v\n", err)
-				dask.Status.State = fmt.Sprintf("JupyterNetworkPolicy Error: %+v\n", err)
-				return ctrl.Result{}, err
-			}
-			jupyterNetworkPolicy.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-			Debugf(log, "JupyterNetworkPolicy: %+v", *jupyterNetworkPolicy)
-			// set the reference
-			if err := ctrl.SetControllerReference(&dask, jupyterNetworkPolicy, r.Scheme); err != nil {
-				Errorf(log, err, "JupyterNetworkPolicy Error: %+v\n", err)
-				return ctrl.Result{}, err
-			}
-			// ...and create it on the cluster
-			if err := r.Create(ctx, jupyterNetworkPolicy); err != nil {
-				log.Error(err, "unable to create JupyterNetworkPolicy for Dask", "NetworkPolicy", jupyterNetworkPolicy)
-				return ctrl.Result{}, err
-			}
-		}
+	// adaptive and idle clusters are scaled on the Scheduler load, so keep polling
+	result := ctrl.Result{}
+	if (dask.Spec.Adaptive != nil || dask.Spec.IdleTimeout != nil) && !hibernated && !suspended {
+		result.RequeueAfter = schedulerPollInterval
+	}
+	requeueSooner(&result, untilExpiry)
+	requeueSooner(&result, untilRenewal)
+	if dask.Spec.Adaptive == nil {
+		dask.Status.Adaptive = nil
+	}
+	switch {
+	case suspended || hibernated:
+		dcontext.Replicas = 0
+	case dask.Spec.Adaptive != nil:
+		dcontext.Replicas = r.adaptiveReplicas(&dask, dcontext, log)
+	}
 
-		// create Service
-		jupyterService, err := models.JupyterService(dcontext)
-		if err != nil {
-			Errorf(log, err, "JupyterService Error: %+v\n", err)
-			dask.Status.State = fmt.Sprintf("JupyterService Error: %+v\n", err)
-			return ctrl.Result{}, err
-		}
-		jupyterService.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-		Debugf(log, "JupyterService: %+v", *jupyterService)
-		// set the reference
-		if err := ctrl.SetControllerReference(&dask, jupyterService, r.Scheme); err != nil {
-			Errorf(log, err, "DaskConfigs Error: %+v\n", err)
-			return ctrl.Result{}, err
-		}
-		// ...and create it on the cluster
-		if err := r.Create(ctx, jupyterService); err != nil {
-			log.Error(err, "unable to create jupyterService for Dask", "Service", jupyterService)
-			return ctrl.Result{}, err
-		}
+	// hand back the data held by any workers that are about to go - the
+	// workers are held until the Scheduler has finished with them
+	retiring, err := r.scaleDownWorkers(ctx, &dask, &dcontext, workerSets, log)
+	if err != nil {
+		log.Error(err, "unable to retire workers")
+		return r.reconcileFailed(ctx, &dask, "RetireFailed", err)
+	}
+	if retiring {
+		requeueSooner(&result, retirePollInterval)
+	}
 
-		// create Deployment
-		jupyterDeployment, err := models.JupyterDeployment(dcontext.ForNotebook())
+	// Generate desired children, and bring the existing ones in line.
+	for _, child := range r.daskChildren(dcontext) {
+		Debugf(log, "###### Apply %s #######", child.desc)
+		obj, err := child.render()
 		if err != nil {
-			Errorf(log, err, "JupyterDeployment Error: %+v\n", err)
-			dask.Status.State = fmt.Sprintf("JupyterDeployment Error: %+v\n", err)
-			return ctrl.Result{}, err
+			Errorf(log, err, "%s Error: %+v\n", child.desc, err)
+			return r.reconcileFailed(ctx, &dask, "RenderFailed", fmt.Errorf("%s: %v", child.desc, err))
 		}
-		jupyterDeployment.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-		Debugf(log, "JupyterDeployment: %+v", *jupyterDeployment)
-		// set the reference
-		if err := ctrl.SetControllerReference(&dask, jupyterDeployment, r.Scheme); err != nil {
-			Errorf(log, err, "JupyterDeployment Error: %+v\n", err)
-			return ctrl.Result{}, err
-		}
-		// ...and create it on the cluster
-		if err := r.Create(ctx, jupyterDeployment); err != nil {
-			log.Error(err, "unable to create JupyterDeployment for Dask", "Deployment", jupyterDeployment)
-			return ctrl.Result{}, err
-		}
-		r.Recorder.Eventf(&dask, corev1.EventTypeNormal, "Created", "Created Jupyter deployment %q", jupyterDeployment.Name)
-	}
-
-	// create scheduler deployment and service
-	Debugf(log, "###### Create Scheduler #######")
-	if currentSchedulerDeployment == nil {
-
-		// create NetworkPolicy
-		if !dcontext.DisablePolicies {
-			schedulerNetworkPolicy, err := models.DaskSchedulerNetworkPolicy(dcontext.ForNotebook())
+		if !child.enabled {
+			removed, err := removeResource(ctx, r.Client, &dask, obj)
 			if err != nil {
-				Errorf(log, err, "DaskSchedulerNetworkPolicy Error: %+v\n", err)
-				dask.Status.State = fmt.Sprintf("DaskSchedulerNetworkPolicy Error: %+v\n", err)
-				return ctrl.Result{}, err
+				log.Error(err, "unable to remove "+child.desc+" for Dask", "Object", obj)
+				return r.reconcileFailed(ctx, &dask, "DeleteFailed", fmt.Errorf("%s: %v", child.desc, err))
 			}
-			schedulerNetworkPolicy.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-			Debugf(log, "DaskSchedulerNetworkPolicy: %+v", *schedulerNetworkPolicy)
-			// set the reference
-			if err := ctrl.SetControllerReference(&dask, schedulerNetworkPolicy, r.Scheme); err != nil {
-				Errorf(log, err, "DaskSchedulerNetworkPolicy Error: %+v\n", err)
-				return ctrl.Result{}, err
+			if removed {
+				r.Recorder.Eventf(&dask, corev1.EventTypeNormal, "Deleted", "Deleted %s %q", child.desc, objectName(obj))
 			}
-			// ...and create it on the cluster
-			if err := r.Create(ctx, schedulerNetworkPolicy); err != nil {
-				log.Error(err, "unable to create DaskSchedulerNetworkPolicy for Dask", "NetworkPolicy", schedulerNetworkPolicy)
-				return ctrl.Result{}, err
+			continue
+		}
+		if service, ok := obj.(*corev1.Service); ok {
+			if err := r.keepNodePorts(ctx, service); err != nil {
+				return r.reconcileFailed(ctx, &dask, "ApplyFailed", fmt.Errorf("%s: %v", child.desc, err))
 			}
 		}
-
-		// create Service
-		schedulerService, err := models.DaskSchedulerService(dcontext)
+		created, changed, err := applyResource(ctx, r.Client, r.Scheme, &dask, obj, log)
 		if err != nil {
-			Errorf(log, err, "DaskSchedulerService Error: %+v\n", err)
-			dask.Status.State = fmt.Sprintf("DaskSchedulerService Error: %+v\n", err)
-			return ctrl.Result{}, err
+			log.Error(err, "unable to apply "+child.desc+" for Dask", "Object", obj)
+			return r.reconcileFailed(ctx, &dask, "ApplyFailed", fmt.Errorf("%s: %v", child.desc, err))
 		}
-		schedulerService.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-		Debugf(log, "DaskSchedulerService: %+v", *schedulerService)
-		// set the reference
-		if err := ctrl.SetControllerReference(&dask, schedulerService, r.Scheme); err != nil {
-			Errorf(log, err, "DaskSchedulerService Error: %+v\n", err)
-			return ctrl.Result{}, err
+		if created {
+			r.Recorder.Eventf(&dask, corev1.EventTypeNormal, "Created", "Created %s %q", child.desc, objectName(obj))
+		} else if len(changed) > 0 {
+			r.Recorder.Eventf(&dask, corev1.EventTypeNormal, "Updated", "Updated %s %q: %s", child.desc, objectName(obj), strings.Join(changed, ", "))
 		}
-		// ...and create it on the cluster
-		if err := r.Create(ctx, schedulerService); err != nil {
-			log.Error(err, "unable to create Service for Dask", "Service", schedulerService)
-			return ctrl.Result{}, err
-		}
-
-		// create Deployment
-		schedulerDeployment, err := models.DaskSchedulerDeployment(dcontext.ForScheduler())
-		if err != nil {
-			Errorf(log, err, "DaskSchedulerDeployment Error: %+v\n", err)
-			dask.Status.State = fmt.Sprintf("DaskSchedulerDeployment Error: %+v\n", err)
-			return ctrl.Result{}, err
-		}
-		schedulerDeployment.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-		Debugf(log, "DaskSchedulerDeployment: %+v", *schedulerDeployment)
-		// set the reference
-		if err := ctrl.SetControllerReference(&dask, schedulerDeployment, r.Scheme); err != nil {
-			Errorf(log, err, "DaskSchedulerDeployment Error: %+v\n", err)
-			return ctrl.Result{}, err
-		}
-		// ...and create it on the cluster
-		if err := r.Create(ctx, schedulerDeployment); err != nil {
-			log.Error(err, "unable to create Deployment for Dask", "Deployment", schedulerDeployment)
-			return ctrl.Result{}, err
-		}
-		r.Recorder.Eventf(&dask, corev1.EventTypeNormal, "Created", "Created Scheduler deployment %q", schedulerDeployment.Name)
 	}
 
-	// create worker cluster
-	Debugf(log, "###### Create Worker #######")
-	if currentWorkerDeployment == nil {
-
-		// create NetworkPolicy
-		if !dcontext.DisablePolicies {
-			workerNetworkPolicy, err := models.DaskWorkerNetworkPolicy(dcontext.ForNotebook())
-			if err != nil {
-				Errorf(log, err, "DaskWorkerNetworkPolicy Error: %+v\n", err)
-				dask.Status.State = fmt.Sprintf("DaskWorkerNetworkPolicy Error: %+v\n", err)
-				return ctrl.Result{}, err
-			}
-			workerNetworkPolicy.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-			Debugf(log, "DaskWorkerNetworkPolicy: %+v", *workerNetworkPolicy)
-			// set the reference
-			if err := ctrl.SetControllerReference(&dask, workerNetworkPolicy, r.Scheme); err != nil {
-				Errorf(log, err, "DaskWorkerNetworkPolicy Error: %+v\n", err)
-				return ctrl.Result{}, err
-			}
-			// ...and create it on the cluster
-			if err := r.Create(ctx, workerNetworkPolicy); err != nil {
-				log.Error(err, "unable to create DaskWorkerNetworkPolicy for Dask", "NetworkPolicy", workerNetworkPolicy)
-				return ctrl.Result{}, err
-			}
-		}
-
-		// create Deployment
-		workerDeployment, err := models.DaskWorkerDeployment(dcontext.ForWorker())
-		if err != nil {
-			Errorf(log, err, "DaskWorkerDeployment Error: %+v\n", err)
-			dask.Status.State = fmt.Sprintf("DaskWorkerDeployment Error: %+v\n", err)
-			return ctrl.Result{}, err
+	// clean up the Deployments and StatefulSets of worker groups that have
+	// been dropped
+	groups := map[string]bool{}
+	for _, group := range dask.Spec.WorkerGroups {
+		groups[group.Name] = true
+	}
+	for _, workerSet := range workerSets {
+		group, ok := workerSet.GetLabels()[workerGroupLabel]
+		if !ok || groups[group] {
+			continue
 		}
-		workerDeployment.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-		Debugf(log, "DaskWorkerDeployment: %+v", *workerDeployment)
-		// set the reference
-		if err := ctrl.SetControllerReference(&dask, workerDeployment, r.Scheme); err != nil {
-			Errorf(log, err, "DaskWorkerDeployment Error: %+v\n", err)
-			return ctrl.Result{}, err
+		desc := "Worker group deployment"
+		if _, ok := workerSet.(*appsv1.StatefulSet); ok {
+			desc = "Worker group statefulset"
 		}
-		// ...and create it on the cluster
-		if err := r.Create(ctx, workerDeployment); err != nil {
-			log.Error(err, "unable to create Deployment for Dask", "Deployment", workerDeployment)
-			return ctrl.Result{}, err
+		removed, err := removeResource(ctx, r.Client, &dask, workerSet)
+		if err != nil {
+			log.Error(err, "unable to remove "+desc+" for Dask", "Object", workerSet)
+			return r.reconcileFailed(ctx, &dask, "DeleteFailed", fmt.Errorf("%s: %v", desc, err))
 		}
-		r.Recorder.Eventf(&dask, corev1.EventTypeNormal, "Created", "Created Worker deployment %q", workerDeployment.Name)
-	} else {
-		// check the replicas
-		if *currentWorkerDeployment.Spec.Replicas != dcontext.Replicas {
-			*currentWorkerDeployment.Spec.Replicas = dcontext.Replicas
-			if err := r.Update(ctx, currentWorkerDeployment); err != nil {
-				log.Error(err, "unable to update Deployment for Dask", "Deployment", currentWorkerDeployment)
-				return ctrl.Result{}, err
-			}
-			r.Recorder.Eventf(&dask, corev1.EventTypeNormal, "Updated", "Updated Worker deployment %q", currentWorkerDeployment.Name)
+		if removed {
+			r.Recorder.Eventf(&dask, corev1.EventTypeNormal, "Deleted", "Deleted %s %q", desc, workerSet.GetName())
 		}
 	}
 
-	// if required, create Ingress for scheduler and jupyter
-	Debugf(log, "###### Create Ingress #######")
-	if currentIngress == nil {
-		if dcontext.JupyterIngress != "" || dcontext.SchedulerIngress != "" {
-
-			daskIngress, err := models.DaskIngress(dcontext)
+	// whether the cluster keeps to the policies of its namespace
+	if err := r.policyCondition(ctx, &dask, class, dcontext); err != nil {
+		log.Error(err, "unable to check the DaskPolicies")
+		return ctrl.Result{}, err
+	}
 
-			if err != nil {
-				Errorf(log, err, "DaskIngress Error: %+v\n", err)
-				dask.Status.State = fmt.Sprintf("DaskIngress Error: %+v\n", err)
-				return ctrl.Result{}, err
-			}
-			daskIngress.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&dask, analyticsv1.GroupVersion.WithKind("Dask"))}
-			Debugf(log, "DaskIngress: %+v", *daskIngress)
-			// set the reference
-			if err := ctrl.SetControllerReference(&dask, daskIngress, r.Scheme); err != nil {
-				Errorf(log, err, "DaskIngress Error: %+v\n", err)
-				return ctrl.Result{}, err
-			}
-			// ...and create it on the cluster
-			if err := r.Create(ctx, daskIngress); err != nil {
-				log.Error(err, "unable to create DaskIngress for Dask", "Ingress", daskIngress)
-				return ctrl.Result{}, err
-			}
-			r.Recorder.Eventf(&dask, corev1.EventTypeNormal, "Created", "Created Ingress deployment %q", daskIngress.Name)
-		}
+	// Compute status based on latest observed state.
+	if err := r.daskConditions(ctx, &dask, dcontext); err != nil {
+		log.Error(err, "unable to read back the Dask components")
+		return ctrl.Result{}, err
 	}
+	dask.Status.ObservedGeneration = dask.Generation
+	Infof(log, "Status components: %d, succeeded: %d, state: %s", dask.Status.Components, dask.Status.Succeeded, dask.Status.State)
 
 	// set the status and go home
 	if err := r.Status().Update(ctx, &dask); err != nil {
@@ -424,35 +315,131 @@ func (r *DaskReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return result, nil
+}
+
+// requeueSooner brings forward the next reconcile to within after, if set
+func requeueSooner(result *ctrl.Result, after time.Duration) {
+	if after > 0 && (result.RequeueAfter == 0 || after < result.RequeueAfter) {
+		result.RequeueAfter = after
+	}
+}
+
+// reconcileFailed records an error in the status, and hands it back so
+// that the request is retried
+func (r *DaskReconciler) reconcileFailed(ctx context.Context, dask *analyticsv1.Dask, reason string, err error) (ctrl.Result, error) {
+	dask.Status.State = "Error"
+	dask.Status.ObservedGeneration = dask.Generation
+	setCondition(&dask.Status.Conditions, dask.Generation, analyticsv1.DaskReady, false, reason, err.Error())
+	if uerr := r.Status().Update(ctx, dask); uerr != nil {
+		Errorf(r.Log, uerr, "unable to update Dask status: %s", dask.Name)
+	}
+	return ctrl.Result{}, err
+}
+
+// daskChild is one of the resources rendered by the models package that
+// together make up a Dask cluster
+type daskChild struct {
+	desc    string
+	enabled bool
+	render  func() (client.Object, error)
+}
+
+// daskChildren lists the cluster resources in the order they are applied.
+// Disabled resources are still rendered, so that they can be cleaned up.
+func (r *DaskReconciler) daskChildren(dcontext dtypes.DaskContext) []daskChild {
+	policies := !dcontext.DisablePolicies
+	children := []daskChild{
+		{"DNS NetworkPolicy", policies, func() (client.Object, error) { return models.DNSNetworkPolicy(dcontext) }},
+		{"ServiceAccount", true, func() (client.Object, error) { return models.ClusterServiceAccount(dcontext) }},
+		{"ConfigMap", true, func() (client.Object, error) { return models.DaskConfigs(dcontext) }},
+		{"Jupyter NetworkPolicy", dcontext.Jupyter && policies, func() (client.Object, error) { return models.JupyterNetworkPolicy(dcontext.ForNotebook()) }},
+		{"Jupyter service", dcontext.Jupyter, func() (client.Object, error) { return models.JupyterService(dcontext.ForNotebook()) }},
+		{"Jupyter deployment", dcontext.Jupyter, func() (client.Object, error) { return models.JupyterDeployment(dcontext.ForNotebook()) }},
+		{"Scheduler NetworkPolicy", policies, func() (client.Object, error) { return models.DaskSchedulerNetworkPolicy(dcontext) }},
+		{"Scheduler service", true, func() (client.Object, error) { return models.DaskSchedulerService(dcontext.ForScheduler()) }},
+		{"Scheduler deployment", true, func() (client.Object, error) { return models.DaskSchedulerDeployment(dcontext.ForScheduler()) }},
+		{"Scheduler PodDisruptionBudget", dcontext.SchedulerPDB, func() (client.Object, error) { return r.daskPodDisruptionBudget(dcontext, true) }},
+		{"Worker NetworkPolicy", policies, func() (client.Object, error) { return models.DaskWorkerNetworkPolicy(dcontext) }},
+		{"Worker deployment", !dcontext.WorkerStatefulSet, func() (client.Object, error) { return models.DaskWorkerDeployment(dcontext.ForWorker()) }},
+		{"Worker service", dcontext.WorkerStatefulSet, func() (client.Object, error) { return models.DaskWorkerService(dcontext) }},
+		{"Worker statefulset", dcontext.WorkerStatefulSet, func() (client.Object, error) { return models.DaskWorkerStatefulSet(dcontext.ForWorker()) }},
+		{"Worker PodDisruptionBudget", dcontext.WorkerPDB, func() (client.Object, error) { return r.daskPodDisruptionBudget(dcontext, false) }},
+		{"Ingress", (dcontext.JupyterIngress != "" || dcontext.SchedulerIngress != "") && !dcontext.Gateway, func() (client.Object, error) { return r.daskIngress(dcontext) }},
+	}
+	// HTTPRoutes can only be managed where the Gateway API is installed
+	if r.gatewayAPIVersion != "" {
+		dcontext.GatewayAPIVersion = r.gatewayAPIVersion
+		children = append(children,
+			daskChild{"Jupyter HTTPRoute", dcontext.Jupyter && dcontext.Gateway, func() (client.Object, error) { return models.JupyterHTTPRoute(dcontext) }},
+			daskChild{"Dashboard HTTPRoute", dcontext.Gateway, func() (client.Object, error) { return models.DashboardHTTPRoute(dcontext) }})
+	}
+	for _, group := range dcontext.WorkerGroups {
+		gcontext := dcontext.ForWorkerGroup(group)
+		children = append(children,
+			daskChild{"Worker group deployment", !dcontext.WorkerStatefulSet, func() (client.Object, error) { return models.DaskWorkerDeployment(gcontext) }},
+			daskChild{"Worker group statefulset", dcontext.WorkerStatefulSet, func() (client.Object, error) { return models.DaskWorkerStatefulSet(gcontext) }})
+	}
+	return children
 }
 
 // SetupWithManager bootstrap reconciler
 func (r *DaskReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
-	if err := mgr.GetFieldIndexer().IndexField(&appsv1.Deployment{}, daskOwnerKey, func(rawObj runtime.Object) []string {
-		// grab the Deployment object, extract the owner...
-		deployment := rawObj.(*appsv1.Deployment)
-		owner := metav1.GetControllerOf(deployment)
-		if owner == nil {
-			return nil
-		}
-		// ...make sure it's a Dask ...
-		if owner.APIVersion != daskApiGVStr || owner.Kind != "Dask" {
-			return nil
+	for _, obj := range []client.Object{&appsv1.Deployment{}, &appsv1.StatefulSet{}} {
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), obj, daskOwnerKey, func(rawObj client.Object) []string {
+			// grab the Deployment or StatefulSet object, extract the owner...
+			owner := metav1.GetControllerOf(rawObj)
+			if owner == nil {
+				return nil
+			}
+			// ...make sure it's a Dask ...
+			if owner.APIVersion != daskApiGVStr || owner.Kind != "Dask" {
+				return nil
+			}
+
+			// ...and if so, return it
+			return []string{owner.Name}
+		}); err != nil {
+			return err
 		}
+	}
 
-		// ...and if so, return it
-		return []string{owner.Name}
-	}); err != nil {
+	// use the newest Ingress API that the API server has
+	if _, err := mgr.GetRESTMapper().RESTMapping(schema.GroupKind{Group: networkingv1.GroupName, Kind: "Ingress"}, "v1"); err != nil {
+		if !meta.IsNoMatchError(err) {
+			return err
+		}
+		r.ingressV1beta1 = true
+	}
+	// and likewise for PodDisruptionBudgets
+	if _, err := mgr.GetRESTMapper().RESTMapping(schema.GroupKind{Group: policyv1.GroupName, Kind: "PodDisruptionBudget"}, "v1"); err != nil {
+		if !meta.IsNoMatchError(err) {
+			return err
+		}
+		r.pdbV1beta1 = true
+	}
+	gatewayAPIVersion, err := discoverGatewayAPI(mgr.GetRESTMapper())
+	if err != nil {
 		return err
 	}
+	r.gatewayAPIVersion = gatewayAPIVersion
 
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&analyticsv1.Dask{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.ConfigMap{}).
-		Owns(&extv1beta1.Ingress{}).
-		Complete(r)
+		Owns(&corev1.Secret{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(r.newPodDisruptionBudget()).
+		Owns(r.newIngress()).
+		Watches(&source.Kind{Type: &analyticsv1.DaskClusterClass{}}, handler.EnqueueRequestsFromMapFunc(r.classDasks)).
+		Watches(&source.Kind{Type: &analyticsv1.DaskPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.policyDasks))
+	if r.gatewayAPIVersion != "" {
+		builder = builder.Owns(r.newHTTPRoute())
+	}
+	return builder.Complete(r)
 }
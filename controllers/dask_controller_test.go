@@ -17,13 +17,21 @@ package controllers
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	policy "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
@@ -50,7 +58,7 @@ var _ = Context("Inside of a new namespace", func() {
 				},
 				Spec: analyticsv1.DaskSpec{
 					Jupyter:          true,
-					Replicas:         initialReplicas,
+					Replicas:         &initialReplicas,
 					Image:            "piersharding/arl-dask:latest",
 					JupyterIngress:   "notebook.dask.local",
 					SchedulerIngress: "scheduler.dask.local",
@@ -166,7 +174,8 @@ var _ = Context("Inside of a new namespace", func() {
 			err = k8sClient.Get(ctx, daskObjectKey, dask)
 			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
 
-			dask.Spec.Replicas = int32(2)
+			replicas := int32(2)
+			dask.Spec.Replicas = &replicas
 			err = k8sClient.Update(ctx, dask)
 			Expect(err).NotTo(HaveOccurred(), "failed to Update Dask resource")
 			deploymentObjectKey := client.ObjectKey{
@@ -178,6 +187,982 @@ var _ = Context("Inside of a new namespace", func() {
 				Should(Equal(int32(2)), "expected Worker Deployment resource to be scale to 2 replicas")
 		})
 
+		It("should roll out spec changes to the existing child resources", func() {
+			name := resource_name + "drift"
+			daskObjectKey := client.ObjectKey{
+				Name:      name,
+				Namespace: ns.Name,
+			}
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Jupyter:         true,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			deployment := &apps.Deployment{}
+			for _, prefix := range []string{"dask-scheduler-", "dask-worker-", "jupyter-notebook-"} {
+				Eventually(
+					getResourceFunc(ctx, client.ObjectKey{Name: prefix + name, Namespace: dask.Namespace}, deployment),
+					time.Second*5, time.Millisecond*500).Should(BeNil())
+			}
+
+			err = k8sClient.Get(ctx, daskObjectKey, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
+
+			dask.Spec.Image = "daskdev/dask:2.9.0"
+			dask.Spec.Jupyter = false
+			err = k8sClient.Update(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to Update Dask resource")
+
+			for _, prefix := range []string{"dask-scheduler-", "dask-worker-"} {
+				Eventually(getDeploymentImageFunc(ctx, client.ObjectKey{Name: prefix + name, Namespace: dask.Namespace}),
+					time.Second*5, time.Millisecond*500).
+					Should(Equal("daskdev/dask:2.9.0"), "expected "+prefix+" Deployment to pick up the new image")
+			}
+
+			Eventually(
+				getResourceFunc(ctx, client.ObjectKey{Name: "jupyter-notebook-" + name, Namespace: dask.Namespace}, deployment),
+				time.Second*5, time.Millisecond*500).ShouldNot(BeNil(), "notebook deployment should be removed")
+		})
+
+		It("should create a Deployment for each worker group, and remove dropped groups", func() {
+			name := resource_name + "groups"
+			daskObjectKey := client.ObjectKey{
+				Name:      name,
+				Namespace: ns.Name,
+			}
+			highmem := int32(2)
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					WorkerGroups: []analyticsv1.DaskWorkerGroup{
+						{
+							Name:     "highmem",
+							Replicas: &highmem,
+							DaskDeploymentSpec: analyticsv1.DaskDeploymentSpec{
+								NodeSelector:  map[string]string{"node-class": "highmem"},
+								DaskResources: map[string]string{"MEMORY": "64e9"},
+							},
+						},
+						{Name: "standard"},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			deployment := &apps.Deployment{}
+			Eventually(
+				getResourceFunc(ctx, client.ObjectKey{Name: "dask-worker-" + name + "-highmem", Namespace: dask.Namespace}, deployment),
+				time.Second*5, time.Millisecond*500).Should(BeNil())
+
+			Expect(*deployment.Spec.Replicas).To(Equal(highmem))
+			Expect(deployment.Spec.Template.Labels).To(HaveKeyWithValue("app.kubernetes.io/name", "dask-worker"))
+			Expect(deployment.Spec.Template.Labels).To(HaveKeyWithValue(workerGroupLabel, "highmem"))
+			Expect(deployment.Spec.Template.Spec.NodeSelector).To(HaveKeyWithValue("node-class", "highmem"))
+			Expect(deployment.Spec.Template.Spec.Containers[0].Env).To(ContainElement(core.EnvVar{Name: "DASK_RESOURCES", Value: "MEMORY=64e9"}))
+
+			Eventually(
+				getResourceFunc(ctx, client.ObjectKey{Name: "dask-worker-" + name + "-standard", Namespace: dask.Namespace}, deployment),
+				time.Second*5, time.Millisecond*500).Should(BeNil())
+
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(1)))
+
+			Eventually(getDeploymentReplicasFunc(ctx, client.ObjectKey{Name: "dask-worker-" + name, Namespace: dask.Namespace}),
+				time.Second*5, time.Millisecond*500).
+				Should(Equal(int32(0)), "expected the default workers to be unused")
+
+			err = k8sClient.Get(ctx, daskObjectKey, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
+
+			dask.Spec.WorkerGroups = dask.Spec.WorkerGroups[:1]
+			err = k8sClient.Update(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to Update Dask resource")
+
+			Eventually(
+				getResourceFunc(ctx, client.ObjectKey{Name: "dask-worker-" + name + "-standard", Namespace: dask.Namespace}, deployment),
+				time.Second*5, time.Millisecond*500).ShouldNot(BeNil(), "dropped worker group should be removed")
+		})
+
+		It("should report conditions and endpoints in the Dask status", func() {
+			name := resource_name + "status"
+			daskObjectKey := client.ObjectKey{
+				Name:      name,
+				Namespace: ns.Name,
+			}
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Jupyter:          true,
+					Replicas:         &initialReplicas,
+					Image:            "piersharding/arl-dask:latest",
+					SchedulerIngress: "scheduler.dask.local",
+					MonitorIngress:   "monitor.dask.local",
+					ImagePullPolicy:  "IfNotPresent",
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			Eventually(func() int64 {
+				if err := k8sClient.Get(ctx, daskObjectKey, dask); err != nil {
+					return 0
+				}
+				return dask.Status.ObservedGeneration
+			}, time.Second*5, time.Millisecond*500).Should(Equal(dask.Generation))
+
+			Eventually(func() []string {
+				Expect(k8sClient.Get(ctx, daskObjectKey, dask)).To(Succeed())
+				var types []string
+				for _, condition := range dask.Status.Conditions {
+					types = append(types, condition.Type)
+				}
+				return types
+			}, time.Second*5, time.Millisecond*500).Should(ConsistOf(
+				analyticsv1.DaskReady,
+				analyticsv1.DaskSchedulerReady,
+				analyticsv1.DaskWorkersReady,
+				analyticsv1.DaskNotebookReady,
+				analyticsv1.DaskIngressReady))
+
+			// there are no Pods behind the Deployments in the test environment
+			Expect(meta.IsStatusConditionFalse(dask.Status.Conditions, analyticsv1.DaskReady)).To(BeTrue())
+			Expect(dask.Status.State).To(Equal("Building"))
+			Expect(dask.Status.Workers).To(Equal(initialReplicas))
+			Expect(dask.Status.Selector).To(Equal("app.kubernetes.io/name=dask-worker,app.kubernetes.io/instance=" + name + ",!" + workerGroupLabel))
+			Expect(dask.Status.SchedulerAddress).To(Equal("tcp://dask-scheduler-" + name + "." + ns.Name + ":8786"))
+			Expect(dask.Status.DashboardURL).To(Equal("http://monitor.dask.local/"))
+			Expect(dask.Status.JupyterURL).To(Equal("http://jupyter-notebook-" + name + "." + ns.Name + ":8888/"))
+		})
+
+		It("should scale everything to zero while suspended, and restore it on resume", func() {
+			name := resource_name + "suspend"
+			daskObjectKey := client.ObjectKey{
+				Name:      name,
+				Namespace: ns.Name,
+			}
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Jupyter:         true,
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Suspend:         true,
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			schedulerKey := client.ObjectKey{Name: "dask-scheduler-" + name, Namespace: ns.Name}
+			workerKey := client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}
+			notebookKey := client.ObjectKey{Name: "jupyter-notebook-" + name, Namespace: ns.Name}
+			for _, key := range []client.ObjectKey{schedulerKey, workerKey, notebookKey} {
+				Eventually(getDeploymentReplicasFunc(ctx, key), time.Second*5, time.Millisecond*500).
+					Should(Equal(int32(0)), "expected %s to be scaled to zero", key.Name)
+			}
+
+			Eventually(func() string {
+				Expect(k8sClient.Get(ctx, daskObjectKey, dask)).To(Succeed())
+				return dask.Status.State
+			}, time.Second*5, time.Millisecond*500).Should(Equal("Suspended"))
+			Expect(meta.IsStatusConditionTrue(dask.Status.Conditions, analyticsv1.DaskSuspended)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "dask-configs-" + name, Namespace: ns.Name}, &core.ConfigMap{})).To(Succeed())
+			Expect(k8sClient.Get(ctx, schedulerKey, &core.Service{})).To(Succeed())
+
+			dask.Spec.Suspend = false
+			err = k8sClient.Update(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to resume Dask resource")
+
+			Eventually(getDeploymentReplicasFunc(ctx, schedulerKey), time.Second*5, time.Millisecond*500).Should(Equal(int32(1)))
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey), time.Second*5, time.Millisecond*500).Should(Equal(initialReplicas))
+			Eventually(getDeploymentReplicasFunc(ctx, notebookKey), time.Second*5, time.Millisecond*500).Should(Equal(int32(1)))
+		})
+
+		It("should keep the Jupyter credentials in a Secret", func() {
+			name := resource_name + "auth"
+			daskObjectKey := client.ObjectKey{
+				Name:      name,
+				Namespace: ns.Name,
+			}
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Jupyter:         true,
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			getAuthEnvFunc := func() []core.EnvVar {
+				depl := &apps.Deployment{}
+				if err := k8sClient.Get(ctx, client.ObjectKey{Name: "jupyter-notebook-" + name, Namespace: ns.Name}, depl); err != nil {
+					return nil
+				}
+				var env []core.EnvVar
+				for _, e := range depl.Spec.Template.Spec.Containers[0].Env {
+					if strings.HasPrefix(e.Name, "JUPYTER_") {
+						env = append(env, e)
+					}
+				}
+				return env
+			}
+
+			// a generated password
+			generatedKey := client.ObjectKey{Name: "jupyter-auth-" + name, Namespace: ns.Name}
+			Eventually(getAuthEnvFunc, time.Second*5, time.Millisecond*500).Should(ConsistOf(core.EnvVar{
+				Name: "JUPYTER_PASSWORD",
+				ValueFrom: &core.EnvVarSource{SecretKeyRef: &core.SecretKeySelector{
+					LocalObjectReference: core.LocalObjectReference{Name: generatedKey.Name},
+					Key:                  "password",
+				}},
+			}))
+			generated := &core.Secret{}
+			Expect(k8sClient.Get(ctx, generatedKey, generated)).To(Succeed())
+			Expect(generated.Data["password"]).To(HaveLen(48))
+			Eventually(func() string {
+				Expect(k8sClient.Get(ctx, daskObjectKey, dask)).To(Succeed())
+				return dask.Status.JupyterAuthSecret
+			}, time.Second*5, time.Millisecond*500).Should(Equal(generatedKey.Name))
+
+			// switched to a token from a Secret of our own
+			own := &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-token", Namespace: ns.Name},
+				StringData: map[string]string{"token": "s3cr3t"},
+			}
+			Expect(k8sClient.Create(ctx, own)).To(Succeed())
+			dask.Spec.JupyterAuth = &analyticsv1.DaskJupyterAuthSpec{
+				Type: "token",
+				SecretKeyRef: &core.SecretKeySelector{
+					LocalObjectReference: core.LocalObjectReference{Name: "my-token"},
+					Key:                  "token",
+				},
+			}
+			Expect(k8sClient.Update(ctx, dask)).To(Succeed())
+
+			Eventually(getAuthEnvFunc, time.Second*5, time.Millisecond*500).Should(ConsistOf(core.EnvVar{
+				Name: "JUPYTER_TOKEN",
+				ValueFrom: &core.EnvVarSource{SecretKeyRef: &core.SecretKeySelector{
+					LocalObjectReference: core.LocalObjectReference{Name: "my-token"},
+					Key:                  "token",
+				}},
+			}))
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, generatedKey, &core.Secret{})
+				return apierrors.IsNotFound(err)
+			}, time.Second*5, time.Millisecond*500).Should(BeTrue(), "expected the generated Secret to be removed")
+		})
+
+		It("should only seed the Jupyter auth Secret from the deprecated jupyterPassword", func() {
+			name := resource_name + "password"
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Jupyter:         true,
+					JupyterPassword: "first",
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			generatedKey := client.ObjectKey{Name: "jupyter-auth-" + name, Namespace: ns.Name}
+			getPasswordFunc := func() string {
+				generated := &core.Secret{}
+				if err := k8sClient.Get(ctx, generatedKey, generated); err != nil {
+					return ""
+				}
+				return string(generated.Data["password"])
+			}
+			Eventually(getPasswordFunc, time.Second*5, time.Millisecond*500).Should(Equal("first"))
+
+			// the Secret holds the password from now on
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask)).To(Succeed())
+			dask.Spec.JupyterPassword = "second"
+			Expect(k8sClient.Update(ctx, dask)).To(Succeed())
+			Consistently(getPasswordFunc, time.Second*2, time.Millisecond*500).Should(Equal("first"))
+		})
+
+		It("should run JupyterLab wired to the cluster under the base URL", func() {
+			name := resource_name + "lab"
+			daskObjectKey := client.ObjectKey{
+				Name:      name,
+				Namespace: ns.Name,
+			}
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Jupyter:         true,
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Notebook: &analyticsv1.DaskNotebookSpec{
+						Mode:    "lab",
+						BaseURL: "/jupyter",
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			depl := &apps.Deployment{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "jupyter-notebook-" + name, Namespace: ns.Name}, depl)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			container := depl.Spec.Template.Spec.Containers[0]
+			Expect(container.Env).To(ContainElements(
+				core.EnvVar{Name: "NOTEBOOK_MODE", Value: "lab"},
+				core.EnvVar{Name: "NOTEBOOK_BASE_URL", Value: "/jupyter/"},
+				core.EnvVar{Name: "DASK_SCHEDULER_ADDRESS", Value: "dask-scheduler-" + name + "." + ns.Name + ":8786"},
+				core.EnvVar{Name: "DASK_DASHBOARD_URL", Value: "/jupyter/proxy/dask-scheduler-" + name + "." + ns.Name + ":8787/status"},
+				core.EnvVar{Name: "DASK_DASHBOARD_HOST", Value: "dask-scheduler-" + name + "." + ns.Name},
+			))
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/jupyter/api"))
+
+			Eventually(func() string {
+				Expect(k8sClient.Get(ctx, daskObjectKey, dask)).To(Succeed())
+				return dask.Status.JupyterURL
+			}, time.Second*5, time.Millisecond*500).Should(Equal("http://jupyter-notebook-" + name + "." + ns.Name + ":8888/jupyter/lab"))
+		})
+
+		It("should create a networking.k8s.io/v1 Ingress, and follow changes to the hostnames", func() {
+			name := resource_name + "ingress"
+			ingressKey := client.ObjectKey{Name: "dask-" + name, Namespace: ns.Name}
+			className := "public"
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Jupyter:          true,
+					Replicas:         &initialReplicas,
+					Image:            "piersharding/arl-dask:latest",
+					ImagePullPolicy:  "IfNotPresent",
+					JupyterIngress:   "notebook.dask.local",
+					SchedulerIngress: "scheduler.dask.local",
+					MonitorIngress:   "monitor.dask.local",
+					Ingress: &analyticsv1.DaskIngressSpec{
+						ClassName:   &className,
+						Annotations: map[string]string{"example.com/owner": "analytics"},
+						TLS: []networking.IngressTLS{{
+							Hosts:      []string{"notebook.dask.local"},
+							SecretName: "notebook-tls",
+						}},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			ingress := &networking.Ingress{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, ingressKey, ingress)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			Expect(ingress.Spec.IngressClassName).To(Equal(&className))
+			Expect(ingress.Annotations).To(HaveKeyWithValue("example.com/owner", "analytics"))
+			Expect(ingress.Annotations).NotTo(HaveKey("kubernetes.io/ingress.class"))
+			Expect(ingress.Spec.TLS).To(Equal(dask.Spec.Ingress.TLS))
+			Expect(ingress.Spec.Rules).To(HaveLen(3))
+			Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal("jupyter-notebook-" + name))
+
+			Eventually(func() string {
+				Expect(k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask)).To(Succeed())
+				return dask.Status.JupyterURL
+			}, time.Second*5, time.Millisecond*500).Should(Equal("https://notebook.dask.local/"))
+
+			// a changed hostname is carried through to the rules
+			dask.Spec.JupyterIngress = "lab.dask.local"
+			Expect(k8sClient.Update(ctx, dask)).To(Succeed())
+			Eventually(func() []string {
+				if err := k8sClient.Get(ctx, ingressKey, ingress); err != nil {
+					return nil
+				}
+				var hosts []string
+				for _, rule := range ingress.Spec.Rules {
+					hosts = append(hosts, rule.Host)
+				}
+				return hosts
+			}, time.Second*5, time.Millisecond*500).Should(Equal([]string{"lab.dask.local", "scheduler.dask.local", "monitor.dask.local"}))
+		})
+
+		It("should expose the Scheduler through a LoadBalancer, and report its address", func() {
+			name := resource_name + "lb"
+			daskObjectKey := client.ObjectKey{Name: name, Namespace: ns.Name}
+			serviceKey := client.ObjectKey{Name: "dask-scheduler-" + name, Namespace: ns.Name}
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Scheduler: &analyticsv1.DaskSchedulerSpec{
+						Service: &analyticsv1.DaskServiceSpec{
+							Type:                     core.ServiceTypeLoadBalancer,
+							Annotations:              map[string]string{"example.com/load-balancer": "internal"},
+							LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+							NodePorts:                map[string]int32{"scheduler": 31786},
+						},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			service := &core.Service{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, serviceKey, service)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			Expect(service.Spec.Type).To(Equal(core.ServiceTypeLoadBalancer))
+			Expect(service.Annotations).To(HaveKeyWithValue("example.com/load-balancer", "internal"))
+			Expect(service.Spec.LoadBalancerSourceRanges).To(Equal([]string{"10.0.0.0/8"}))
+			Expect(service.Spec.Ports[0].NodePort).To(Equal(int32(31786)))
+			bokehNodePort := service.Spec.Ports[1].NodePort
+			Expect(bokehNodePort).NotTo(BeZero())
+
+			// the load balancer is given an address
+			service.Status.LoadBalancer.Ingress = []core.LoadBalancerIngress{{IP: "203.0.113.10"}}
+			Expect(k8sClient.Status().Update(ctx, service)).To(Succeed())
+			Eventually(func() string {
+				Expect(k8sClient.Get(ctx, daskObjectKey, dask)).To(Succeed())
+				return dask.Status.SchedulerExternalAddress
+			}, time.Second*5, time.Millisecond*500).Should(Equal("tcp://203.0.113.10:8786"))
+
+			// the allocated node port survives an update to the Service
+			dask.Spec.Scheduler.Service.Annotations["example.com/load-balancer"] = "external"
+			Expect(k8sClient.Update(ctx, dask)).To(Succeed())
+			Eventually(func() string {
+				Expect(k8sClient.Get(ctx, serviceKey, service)).To(Succeed())
+				return service.Annotations["example.com/load-balancer"]
+			}, time.Second*5, time.Millisecond*500).Should(Equal("external"))
+			Expect(service.Spec.Ports[1].NodePort).To(Equal(bokehNodePort))
+		})
+
+		It("should merge the podTemplate of a component over its Pods", func() {
+			name := resource_name + "podtemplate"
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Worker: &analyticsv1.DaskWorkerSpec{
+						DaskDeploymentSpec: analyticsv1.DaskDeploymentSpec{
+							PodTemplate: &runtime.RawExtension{Raw: []byte(`{
+								"metadata": {"labels": {"team": "analytics"}},
+								"spec": {
+									"priorityClassName": "dask-workers",
+									"initContainers": [{"name": "fetch", "image": "busybox"}],
+									"containers": [
+										{"name": "worker", "envFrom": [{"secretRef": {"name": "credentials"}}]},
+										{"name": "sidecar", "image": "busybox"}
+									]
+								}
+							}`)},
+						},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			depl := &apps.Deployment{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}, depl)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			template := depl.Spec.Template
+			Expect(template.Labels).To(HaveKeyWithValue("team", "analytics"))
+			Expect(template.Labels).To(HaveKeyWithValue("app.kubernetes.io/name", "dask-worker"))
+			Expect(template.Spec.PriorityClassName).To(Equal("dask-workers"))
+			Expect(template.Spec.InitContainers).To(HaveLen(1))
+			Expect(template.Spec.Containers).To(HaveLen(2))
+			Expect(template.Spec.Containers[0].Name).To(Equal("worker"))
+			Expect(template.Spec.Containers[0].Image).To(Equal("piersharding/arl-dask:latest"))
+			Expect(template.Spec.Containers[0].EnvFrom).To(HaveLen(1))
+
+			// the other components are left alone
+			scheduler := &apps.Deployment{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "dask-scheduler-" + name, Namespace: ns.Name}, scheduler)).To(Succeed())
+			Expect(scheduler.Spec.Template.Spec.Containers).To(HaveLen(1))
+			Expect(scheduler.Spec.Template.Spec.PriorityClassName).To(BeEmpty())
+		})
+
+		It("should pass the worker resources, threads, processes and memory fraction to the workers", func() {
+			name := resource_name + "workersettings"
+			nthreads := int32(4)
+			nworkers := int32(2)
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Worker: &analyticsv1.DaskWorkerSpec{
+						DaskDeploymentSpec: analyticsv1.DaskDeploymentSpec{
+							DaskResources:  map[string]string{"GPU": "1", "BIGMEM": "1"},
+							NWorkers:       &nworkers,
+							MemoryFraction: "0.6",
+							Resources: &core.ResourceRequirements{
+								Requests: core.ResourceList{
+									core.ResourceCPU:    resource.MustParse("2"),
+									core.ResourceMemory: resource.MustParse("8Gi"),
+								},
+							},
+						},
+					},
+					WorkerGroups: []analyticsv1.DaskWorkerGroup{
+						{
+							Name: "threads",
+							DaskDeploymentSpec: analyticsv1.DaskDeploymentSpec{
+								NThreads: &nthreads,
+							},
+						},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			// only requests are set, so the threads and memory come from those
+			deployment := &apps.Deployment{}
+			Eventually(
+				getResourceFunc(ctx, client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}, deployment),
+				time.Second*5, time.Millisecond*500).Should(BeNil())
+			env := map[string]core.EnvVar{}
+			for _, e := range deployment.Spec.Template.Spec.Containers[0].Env {
+				env[e.Name] = e
+			}
+			Expect(env["DASK_RESOURCES"].Value).To(Equal("BIGMEM=1,GPU=1"))
+			Expect(env["DASK_NWORKERS"].Value).To(Equal("2"))
+			Expect(env["DASK_MEMORY_FRACTION"].Value).To(Equal("0.6"))
+			Expect(env).NotTo(HaveKey("DASK_NTHREADS"))
+			Expect(env["DASK_CPU_MILLICORES"].ValueFrom.ResourceFieldRef.Resource).To(Equal("requests.cpu"))
+			Expect(env["DASK_MEMORY_BYTES"].ValueFrom.ResourceFieldRef.Resource).To(Equal("requests.memory"))
+
+			// the group replaces only what it sets
+			Eventually(
+				getResourceFunc(ctx, client.ObjectKey{Name: "dask-worker-" + name + "-threads", Namespace: ns.Name}, deployment),
+				time.Second*5, time.Millisecond*500).Should(BeNil())
+			env = map[string]core.EnvVar{}
+			for _, e := range deployment.Spec.Template.Spec.Containers[0].Env {
+				env[e.Name] = e
+			}
+			Expect(env["DASK_NTHREADS"].Value).To(Equal("4"))
+			Expect(env["DASK_NWORKERS"].Value).To(Equal("2"))
+			Expect(env["DASK_RESOURCES"].Value).To(Equal("BIGMEM=1,GPU=1"))
+		})
+
+		It("should create PodDisruptionBudgets and set the priority class of each component", func() {
+			name := resource_name + "pdb"
+			minAvailable := intstr.FromString("50%")
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					DisruptionBudget: &analyticsv1.DaskDisruptionBudgetSpec{
+						MinAvailable: &minAvailable,
+					},
+					Scheduler: &analyticsv1.DaskSchedulerSpec{
+						DaskDeploymentSpec: analyticsv1.DaskDeploymentSpec{PriorityClassName: "dask-critical"},
+					},
+					Worker: &analyticsv1.DaskWorkerSpec{
+						DaskDeploymentSpec: analyticsv1.DaskDeploymentSpec{PriorityClassName: "dask-workers"},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			pdb := &policy.PodDisruptionBudget{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "dask-scheduler-" + name, Namespace: ns.Name}, pdb)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			Expect(pdb.Spec.MinAvailable.IntValue()).To(Equal(1))
+			Expect(pdb.Spec.Selector.MatchLabels).To(HaveKeyWithValue("app.kubernetes.io/name", "dask-scheduler"))
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}, pdb)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			Expect(pdb.Spec.MinAvailable.String()).To(Equal("50%"))
+			Expect(pdb.Spec.MaxUnavailable).To(BeNil())
+			Expect(pdb.Spec.Selector.MatchLabels).To(HaveKeyWithValue("app.kubernetes.io/name", "dask-worker"))
+
+			for deployment, priorityClass := range map[string]string{"dask-scheduler-": "dask-critical", "dask-worker-": "dask-workers"} {
+				depl := &apps.Deployment{}
+				Expect(k8sClient.Get(ctx, client.ObjectKey{Name: deployment + name, Namespace: ns.Name}, depl)).To(Succeed())
+				Expect(depl.Spec.Template.Spec.PriorityClassName).To(Equal(priorityClass))
+			}
+
+			// dropping the worker bounds removes the worker PodDisruptionBudget
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask)).To(Succeed())
+			dask.Spec.DisruptionBudget.MinAvailable = nil
+			Expect(k8sClient.Update(ctx, dask)).To(Succeed())
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}, &policy.PodDisruptionBudget{})
+				return apierrors.IsNotFound(err)
+			}, time.Second*5, time.Millisecond*500).Should(BeTrue())
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "dask-scheduler-" + name, Namespace: ns.Name}, pdb)).To(Succeed())
+		})
+
+		It("should run every component as non-root under the restricted securityProfile", func() {
+			name := resource_name + "restricted"
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas:        &initialReplicas,
+					Jupyter:         true,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					SecurityProfile: "restricted",
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			for _, deployment := range []string{"dask-scheduler-", "dask-worker-", "jupyter-notebook-"} {
+				depl := &apps.Deployment{}
+				Eventually(func() error {
+					return k8sClient.Get(ctx, client.ObjectKey{Name: deployment + name, Namespace: ns.Name}, depl)
+				}, time.Second*5, time.Millisecond*500).Should(Succeed())
+				podSecurity := depl.Spec.Template.Spec.SecurityContext
+				Expect(podSecurity).NotTo(BeNil())
+				Expect(*podSecurity.RunAsNonRoot).To(BeTrue())
+				Expect(*podSecurity.RunAsUser).NotTo(BeZero())
+				Expect(podSecurity.FSGroup).NotTo(BeNil())
+				Expect(podSecurity.SeccompProfile.Type).To(Equal(core.SeccompProfileTypeRuntimeDefault))
+				security := depl.Spec.Template.Spec.Containers[0].SecurityContext
+				Expect(security).NotTo(BeNil())
+				Expect(security.RunAsUser).To(BeNil())
+				Expect(*security.AllowPrivilegeEscalation).To(BeFalse())
+				Expect(*security.ReadOnlyRootFilesystem).To(BeTrue())
+				Expect(security.Capabilities.Drop).To(ConsistOf(core.Capability("ALL")))
+				for _, volume := range depl.Spec.Template.Spec.Volumes {
+					Expect(volume.HostPath).To(BeNil())
+				}
+			}
+		})
+
+		It("should mount the merged daskConfig of each component as its dask.yaml", func() {
+			name := resource_name + "daskconfig"
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					DaskConfig: &runtime.RawExtension{Raw: []byte(`{
+						"distributed": {"comm": {"timeouts": {"connect": "30s"}}}
+					}`)},
+					Worker: &analyticsv1.DaskWorkerSpec{
+						DaskDeploymentSpec: analyticsv1.DaskDeploymentSpec{
+							DaskConfig: &runtime.RawExtension{Raw: []byte(`{
+								"distributed": {"worker": {"memory": {"spill": 0.85}}}
+							}`)},
+						},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			configMap := &core.ConfigMap{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "dask-configs-" + name, Namespace: ns.Name}, configMap)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			Expect(configMap.Data["dask.yaml"]).To(ContainSubstring("connect: 30s"))
+			Expect(configMap.Data["dask.yaml"]).NotTo(ContainSubstring("spill"))
+			Expect(configMap.Data["dask-worker.yaml"]).To(ContainSubstring("connect: 30s"))
+			Expect(configMap.Data["dask-worker.yaml"]).To(ContainSubstring("spill: 0.85"))
+
+			for deployment, key := range map[string]string{"dask-scheduler-": "dask.yaml", "dask-worker-": "dask-worker.yaml"} {
+				depl := &apps.Deployment{}
+				Eventually(func() error {
+					return k8sClient.Get(ctx, client.ObjectKey{Name: deployment + name, Namespace: ns.Name}, depl)
+				}, time.Second*5, time.Millisecond*500).Should(Succeed())
+				Expect(depl.Spec.Template.Annotations).To(HaveKey("analytics.piersharding.com/dask-config"))
+				Expect(depl.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(core.VolumeMount{
+					Name:      "dask-script",
+					MountPath: "/etc/dask/dask.yaml",
+					SubPath:   key,
+				}))
+			}
+		})
+
+		It("should run the command, args and start script given for a component", func() {
+			name := resource_name + "command"
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Scheduler: &analyticsv1.DaskSchedulerSpec{
+						DaskDeploymentSpec: analyticsv1.DaskDeploymentSpec{
+							Args: []string{"--idle-timeout", "1h"},
+							StartScript: &core.ConfigMapKeySelector{
+								LocalObjectReference: core.LocalObjectReference{Name: "scripts"},
+								Key:                  "scheduler.sh",
+							},
+						},
+					},
+					Worker: &analyticsv1.DaskWorkerSpec{
+						DaskDeploymentSpec: analyticsv1.DaskDeploymentSpec{
+							Command: []string{"dask-cuda-worker"},
+							Args:    []string{"--rmm-pool-size", "1GB"},
+						},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			// the start script of the Scheduler is replaced
+			depl := &apps.Deployment{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "dask-scheduler-" + name, Namespace: ns.Name}, depl)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			container := depl.Spec.Template.Spec.Containers[0]
+			Expect(container.Command).To(Equal([]string{"/start-dask-scheduler.sh"}))
+			Expect(container.Args).To(Equal([]string{"--idle-timeout", "1h"}))
+			Expect(container.VolumeMounts).To(ContainElement(core.VolumeMount{
+				Name:      "start-script",
+				MountPath: "/start-dask-scheduler.sh",
+				SubPath:   "scheduler.sh",
+			}))
+
+			// the workers run the command, with the environment still set
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}, depl)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			container = depl.Spec.Template.Spec.Containers[0]
+			Expect(container.Command).To(Equal([]string{"dask-cuda-worker"}))
+			Expect(container.Args).To(Equal([]string{"--rmm-pool-size", "1GB"}))
+			env := map[string]string{}
+			for _, e := range container.Env {
+				env[e.Name] = e.Value
+			}
+			Expect(env).To(HaveKey("DASK_SCHEDULER"))
+			Expect(env).To(HaveKey("DASK_PORT_SCHEDULER"))
+		})
+
+		It("should take the defaults that a Dask does not set from its DaskClusterClass", func() {
+			name := resource_name + "class"
+			class := &analyticsv1.DaskClusterClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name,
+				},
+				Spec: analyticsv1.DaskClusterClassSpec{
+					Image:           "daskdev/dask:2022.1.0",
+					ImagePullPolicy: "Always",
+					JupyterImage:    "jupyter/datascience-notebook:latest",
+					Tolerations: []core.Toleration{
+						{Key: "dedicated", Operator: core.TolerationOpEqual, Value: "dask", Effect: core.TaintEffectNoSchedule},
+					},
+					IngressDomain: "dask.example.com",
+				},
+			}
+			Expect(k8sClient.Create(ctx, class)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, class)).To(Succeed())
+			}()
+
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas:        &initialReplicas,
+					Jupyter:         true,
+					ClassName:       name,
+					ImagePullPolicy: "IfNotPresent",
+					Ingress:         &analyticsv1.DaskIngressSpec{},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			// the Dask keeps its own pull policy
+			depl := &apps.Deployment{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}, depl)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			Expect(depl.Spec.Template.Spec.Containers[0].Image).To(Equal("daskdev/dask:2022.1.0"))
+			Expect(depl.Spec.Template.Spec.Containers[0].ImagePullPolicy).To(Equal(core.PullIfNotPresent))
+			Expect(depl.Spec.Template.Spec.Tolerations).To(HaveLen(1))
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "jupyter-notebook-" + name, Namespace: ns.Name}, depl)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			Expect(depl.Spec.Template.Spec.Containers[0].Image).To(Equal("jupyter/datascience-notebook:latest"))
+
+			ingress := &networking.Ingress{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Name: "dask-" + name, Namespace: ns.Name}, ingress)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			var hosts []string
+			for _, rule := range ingress.Spec.Rules {
+				hosts = append(hosts, rule.Host)
+			}
+			Expect(hosts).To(ContainElement("notebook-" + name + "-" + ns.Name + ".dask.example.com"))
+		})
+
+		It("should fail a Dask whose DaskClusterClass does not exist", func() {
+			name := resource_name + "noclass"
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas:  &initialReplicas,
+					ClassName: "missing",
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			Eventually(func() string {
+				if err := k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask); err != nil {
+					return ""
+				}
+				if condition := meta.FindStatusCondition(dask.Status.Conditions, analyticsv1.DaskReady); condition != nil {
+					return condition.Reason
+				}
+				return ""
+			}, time.Second*5, time.Millisecond*500).Should(Equal("ClassNotFound"))
+		})
+
+		It("should report a Dask that breaks the DaskPolicy of its namespace", func() {
+			name := resource_name + "policy"
+			namespace := &core.Namespace{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: ns.Name}, namespace)).To(Succeed())
+			if namespace.Labels == nil {
+				namespace.Labels = map[string]string{}
+			}
+			namespace.Labels["analytics.piersharding.com/tenant"] = name
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+
+			maxWorkers := int32(2)
+			policy := &analyticsv1.DaskPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name,
+				},
+				Spec: analyticsv1.DaskPolicySpec{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"analytics.piersharding.com/tenant": name},
+					},
+					MaxWorkersPerCluster: &maxWorkers,
+					AllowedRegistries:    []string{"registry.example.com"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, policy)).To(Succeed())
+			}()
+
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Replicas: &initialReplicas,
+					Image:    "daskdev/dask:2022.1.0",
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			var condition *metav1.Condition
+			Eventually(func() string {
+				if err := k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask); err != nil {
+					return ""
+				}
+				if condition = meta.FindStatusCondition(dask.Status.Conditions, analyticsv1.DaskPolicyCompliant); condition != nil {
+					return condition.Reason
+				}
+				return ""
+			}, time.Second*5, time.Millisecond*500).Should(Equal("PolicyViolation"))
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(ContainSubstring("image daskdev/dask:2022.1.0 is not allowed by DaskPolicy " + name))
+			Expect(meta.IsStatusConditionFalse(dask.Status.Conditions, analyticsv1.DaskReady)).To(BeTrue())
+		})
+
 		// It("should clean up an old Deployment resource if the deploymentName is changed", func() {
 		// 	deploymentObjectKey := client.ObjectKey{
 		// 		Name:      "deployment-name",
@@ -227,7 +1212,7 @@ var _ = Context("Inside of a new namespace", func() {
 	})
 })
 
-func getResourceFunc(ctx context.Context, key client.ObjectKey, obj runtime.Object) func() error {
+func getResourceFunc(ctx context.Context, key client.ObjectKey, obj client.Object) func() error {
 	return func() error {
 		return k8sClient.Get(ctx, key, obj)
 	}
@@ -242,3 +1227,13 @@ func getDeploymentReplicasFunc(ctx context.Context, key client.ObjectKey) func()
 		return *depl.Spec.Replicas
 	}
 }
+
+func getDeploymentImageFunc(ctx context.Context, key client.ObjectKey) func() string {
+	return func() string {
+		depl := &apps.Deployment{}
+		err := k8sClient.Get(ctx, key, depl)
+		Expect(err).NotTo(HaveOccurred(), "failed to get Deployment resource")
+
+		return depl.Spec.Template.Spec.Containers[0].Image
+	}
+}
@@ -19,8 +19,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
@@ -39,6 +41,13 @@ import (
 var (
 	jobOwnerKey     = ".metadata.daskjobcontroller"
 	daskjobApiGVStr = analyticsv1.GroupVersion.String()
+
+	// suspendedPollInterval is how often a DaskJob checks on a suspended cluster
+	suspendedPollInterval = 30 * time.Second
+
+	// classPollInterval is how often a DaskJob checks for the missing
+	// DaskClusterClass of its cluster
+	classPollInterval = 30 * time.Second
 )
 
 // DaskJobReconciler reconciles a DaskJob object
@@ -57,8 +66,7 @@ type DaskJobReconciler struct {
 // +kubebuilder:rbac:groups=core,resources=configmaps;secrets;persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile main reconcile loop
-func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	ctx := context.Background()
+func (r *DaskJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("daskjob", req.NamespacedName)
 	clog := r.CustomLog.WithValues("daskjob", req.NamespacedName)
 	_ = clog
@@ -73,8 +81,8 @@ func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 
 	daskjob.Status.Succeeded = 0
-	daskjob.Status.Resources = ""
-	daskjob.Status.State = "Building"
+	daskjob.Status.State = "Pending"
+	daskjob.Status.ObservedGeneration = daskjob.Generation
 
 	var dask analyticsv1.Dask
 	daskobjkey := client.ObjectKey{
@@ -86,18 +94,50 @@ func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		// we'll ignore not-found errors, since they can't be fixed by an immediate
 		// requeue (we'll need to wait for a new notification), and we can get them
 		// on deleted requests.
-		daskjob.Status.State = fmt.Sprintf("Pending creation of Dask cluster: %s", daskjob.Spec.Cluster)
+		setCondition(&daskjob.Status.Conditions, daskjob.Generation, analyticsv1.DaskJobClusterReady, false, "NotFound", fmt.Sprintf("Pending creation of Dask cluster: %s", daskjob.Spec.Cluster))
 		r.Status().Update(ctx, &daskjob)
 		return ctrl.Result{}, client.IgnoreNotFound(errors.New("unable to fetch DaskJob(create/delete in progress?): " + err.Error()))
 	}
 
-	if dask.Status.State != "Running" {
-		log.Info(fmt.Sprintf("Dask cluster not ready: %s - %s - %s", daskjob.Spec.Cluster, dask.Status.State, dask.Status.Resources))
+	finished := meta.IsStatusConditionTrue(daskjob.Status.Conditions, analyticsv1.DaskJobComplete) ||
+		meta.IsStatusConditionTrue(daskjob.Status.Conditions, analyticsv1.DaskJobFailed)
+
+	// a suspended cluster is waited on until it is resumed
+	if dask.Spec.Suspend && !finished {
+		message := fmt.Sprintf("Waiting for Dask cluster %s to be resumed", dask.Name)
+		log.Info(message)
+		setCondition(&daskjob.Status.Conditions, daskjob.Generation, analyticsv1.DaskJobClusterReady, false, "ClusterSuspended", message)
+		if err := r.Status().Update(ctx, &daskjob); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: suspendedPollInterval}, nil
+	}
+
+	// a hibernated cluster is woken up to run the job
+	if meta.IsStatusConditionTrue(dask.Status.Conditions, analyticsv1.DaskHibernated) && !finished {
+		if _, ok := dask.Annotations[analyticsv1.DaskWakeUpAnnotation]; !ok {
+			if err := wakeUpDask(ctx, r.Client, &dask); err != nil {
+				log.Error(err, "unable to wake up Dask cluster")
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Eventf(&daskjob, corev1.EventTypeNormal, "WakingUp", "Waking up hibernated Dask cluster %s", dask.Name)
+		}
+	}
+
+	if !meta.IsStatusConditionTrue(dask.Status.Conditions, analyticsv1.DaskReady) {
+		message := fmt.Sprintf("Dask cluster not ready: %s - %s", daskjob.Spec.Cluster, dask.Status.State)
+		if ready := meta.FindStatusCondition(dask.Status.Conditions, analyticsv1.DaskReady); ready != nil {
+			message = message + " - " + ready.Message
+		}
+		log.Info(message)
+		setCondition(&daskjob.Status.Conditions, daskjob.Generation, analyticsv1.DaskJobClusterReady, false, "NotReady", message)
+		r.Status().Update(ctx, &daskjob)
 		// we'll ignore not-found errors, since they can't be fixed by an immediate
 		// requeue (we'll need to wait for a new notification), and we can get them
 		// on deleted requests.
-		return ctrl.Result{}, client.IgnoreNotFound(fmt.Errorf("Dask cluster not ready: %s - %s - %s", daskjob.Spec.Cluster, dask.Status.State, dask.Status.Resources))
+		return ctrl.Result{}, client.IgnoreNotFound(errors.New(message))
 	}
+	setCondition(&daskjob.Status.Conditions, daskjob.Generation, analyticsv1.DaskJobClusterReady, true, "Ready", fmt.Sprintf("Dask cluster %s is ready", daskjob.Spec.Cluster))
 
 	var currentJob *batchv1.Job
 	var currentConfig *corev1.ConfigMap
@@ -140,12 +180,27 @@ func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	} else if finishedState {
 		daskjob.Status.State = string(finishedType)
 	}
+	jobConditions(&daskjob, currentJob)
 	Infof(log, "Status: %s", finishedType)
 
 	Debugf(log, "incoming context: %+v", daskjob)
 
-	// setup configuration.
-	dcontext := dtypes.SetConfig(dask)
+	// setup configuration, over the defaults of the class of the Dask
+	class, err := analyticsv1.ClassFor(ctx, r, &dask)
+	if err != nil {
+		Errorf(log, err, "unable to find the DaskClusterClass: %s", err.Error())
+		var notFound analyticsv1.ClassNotFoundError
+		if !errors.As(err, &notFound) {
+			return ctrl.Result{}, err
+		}
+		// the class may yet be created, so the job waits for it
+		setCondition(&daskjob.Status.Conditions, daskjob.Generation, analyticsv1.DaskJobClusterReady, false, "ClassNotFound", err.Error())
+		if err := r.Status().Update(ctx, &daskjob); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: classPollInterval}, nil
+	}
+	dcontext := dtypes.SetClassConfig(dask, class)
 	dcontext.SetJobConfig(&daskjob)
 
 	// check Script - is it a notebook, script, file or URL
@@ -153,19 +208,15 @@ func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	if err != nil {
 		Errorf(log, err, "DaskJob script is invalid: %s", err.Error())
 		r.Recorder.Eventf(&daskjob, corev1.EventTypeWarning, "Failed", "DaskJob script is invalid: %q", daskjob.Name)
-		return ctrl.Result{Requeue: false, RequeueAfter: 0}, fmt.Errorf("DaskJob script is invalid: %s", err.Error())
+		return r.reconcileFailed(ctx, &daskjob, "InvalidScript", fmt.Errorf("DaskJob script is invalid: %s", err.Error()))
 	}
 	dcontext.ScriptType = scriptType
 	dcontext.ScriptContents = scriptContents
 	dcontext.MountedFile = mountedFile
 
-	// Get resource details
-	resources, err := r.resourceDetails(dcontext)
-	if err != nil {
-		daskjob.Status.State = resources
-		return ctrl.Result{}, err
+	if dcontext.Report {
+		daskjob.Status.ReportClaim = "daskjob-report-pvc-" + daskjob.Name
 	}
-	daskjob.Status.Resources = resources
 
 	// Generate desired children.
 
@@ -175,8 +226,7 @@ func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		configMap, err := models.DaskJobConfigs(dcontext)
 		if err != nil {
 			Errorf(log, err, "DaskJobConfigs Error: %+v\n", err)
-			daskjob.Status.State = fmt.Sprintf("DaskJobConfigs Error: %+v\n", err)
-			return ctrl.Result{}, err
+			return r.reconcileFailed(ctx, &daskjob, "RenderFailed", fmt.Errorf("DaskJobConfigs: %v", err))
 		}
 		configMap.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&daskjob, analyticsv1.GroupVersion.WithKind("DaskJob"))}
 
@@ -202,8 +252,7 @@ func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			daskjobJobReportPVC, err := models.DaskJobReportStorage(dcontext)
 			if err != nil {
 				Errorf(log, err, "DaskJobReportStorage Error: %+v\n", err)
-				daskjob.Status.State = fmt.Sprintf("DaskJobReportStorage Error: %+v\n", err)
-				return ctrl.Result{}, err
+				return r.reconcileFailed(ctx, &daskjob, "RenderFailed", fmt.Errorf("DaskJobReportStorage: %v", err))
 			}
 			daskjobJobReportPVC.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&daskjob, analyticsv1.GroupVersion.WithKind("DaskJob"))}
 			Debugf(log, "DaskJobReportStorage: %+v", *daskjobJobReportPVC)
@@ -224,8 +273,7 @@ func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		daskjobServiceAccount, err := models.JobServiceAccount(dcontext)
 		if err != nil {
 			Errorf(log, err, "JobServiceAccount Error: %+v\n", err)
-			daskjob.Status.State = fmt.Sprintf("JobServiceAccount Error: %+v\n", err)
-			return ctrl.Result{}, err
+			return r.reconcileFailed(ctx, &daskjob, "RenderFailed", fmt.Errorf("JobServiceAccount: %v", err))
 		}
 		daskjobServiceAccount.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&daskjob, analyticsv1.GroupVersion.WithKind("DaskJob"))}
 		Debugf(log, "JobServiceAccount: %+v", *daskjobServiceAccount)
@@ -244,8 +292,7 @@ func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		daskjobJob, err := models.DaskJob(dcontext)
 		if err != nil {
 			Errorf(log, err, "DaskJob Error: %+v\n", err)
-			daskjob.Status.State = fmt.Sprintf("DaskJob Error: %+v\n", err)
-			return ctrl.Result{}, err
+			return r.reconcileFailed(ctx, &daskjob, "RenderFailed", fmt.Errorf("DaskJob: %v", err))
 		}
 		daskjobJob.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(&daskjob, analyticsv1.GroupVersion.WithKind("DaskJob"))}
 		Debugf(log, "DaskJob: %+v", *daskjobJob)
@@ -271,10 +318,66 @@ func (r *DaskJobReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
+// reconcileFailed records an error in the status, and hands it back so
+// that the request is retried
+func (r *DaskJobReconciler) reconcileFailed(ctx context.Context, daskjob *analyticsv1.DaskJob, reason string, err error) (ctrl.Result, error) {
+	daskjob.Status.State = "Error"
+	setCondition(&daskjob.Status.Conditions, daskjob.Generation, analyticsv1.DaskJobFailed, true, reason, err.Error())
+	if uerr := r.Status().Update(ctx, daskjob); uerr != nil {
+		Errorf(r.Log, uerr, "unable to update DaskJob status: %s", daskjob.Name)
+	}
+	return ctrl.Result{}, err
+}
+
+// jobConditions copies the progress of the Job into the DaskJob status
+func jobConditions(daskjob *analyticsv1.DaskJob, job *batchv1.Job) {
+	conditions := &daskjob.Status.Conditions
+	generation := daskjob.Generation
+	if job == nil {
+		setCondition(conditions, generation, analyticsv1.DaskJobComplete, false, "Pending", "Job has not been created yet")
+		meta.RemoveStatusCondition(conditions, analyticsv1.DaskJobFailed)
+		return
+	}
+	daskjob.Status.Active = job.Status.Active
+	daskjob.Status.Succeeded = job.Status.Succeeded
+	daskjob.Status.Failed = job.Status.Failed
+	daskjob.Status.StartTime = job.Status.StartTime
+	daskjob.Status.CompletionTime = job.Status.CompletionTime
+	daskjob.Status.Duration = ""
+	if job.Status.StartTime != nil && job.Status.CompletionTime != nil {
+		daskjob.Status.Duration = job.Status.CompletionTime.Sub(job.Status.StartTime.Time).Round(time.Second).String()
+	}
+
+	complete, failed := false, false
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		reason := c.Reason
+		if reason == "" {
+			reason = string(c.Type)
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			complete = true
+			setCondition(conditions, generation, analyticsv1.DaskJobComplete, true, reason, c.Message)
+		case batchv1.JobFailed:
+			failed = true
+			setCondition(conditions, generation, analyticsv1.DaskJobFailed, true, reason, c.Message)
+		}
+	}
+	if !complete {
+		setCondition(conditions, generation, analyticsv1.DaskJobComplete, false, "Running", fmt.Sprintf("Job %s has %d active pods", job.Name, job.Status.Active))
+	}
+	if !failed {
+		meta.RemoveStatusCondition(conditions, analyticsv1.DaskJobFailed)
+	}
+}
+
 // SetupWithManager bootstrap reconciler
 func (r *DaskJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
-	if err := mgr.GetFieldIndexer().IndexField(&batchv1.Job{}, jobOwnerKey, func(rawObj runtime.Object) []string {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &batchv1.Job{}, jobOwnerKey, func(rawObj client.Object) []string {
 		// grab the Job object, extract the owner...
 		job := rawObj.(*batchv1.Job)
 		owner := metav1.GetControllerOf(job)
@@ -66,7 +66,7 @@ var _ = Context("Inside of a new namespace", func() {
 				},
 				Spec: analyticsv1.DaskSpec{
 					Jupyter:          true,
-					Replicas:         initialReplicas,
+					Replicas:         &initialReplicas,
 					Image:            "daskdev/dask:2.9.0",
 					JupyterIngress:   "notebook.dask.local",
 					SchedulerIngress: "scheduler.dask.local",
@@ -182,7 +182,8 @@ var _ = Context("Inside of a new namespace", func() {
 			err = k8sClient.Get(ctx, daskObjectKey, dask)
 			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
 
-			dask.Spec.Replicas = int32(2)
+			replicas := int32(2)
+			dask.Spec.Replicas = &replicas
 			err = k8sClient.Update(ctx, dask)
 			Expect(err).NotTo(HaveOccurred(), "failed to Update Dask resource")
 			deploymentObjectKey := client.ObjectKey{
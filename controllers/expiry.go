@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultExpiryWarnings are the lead times before expiry at which the
+// Warning events are emitted
+var defaultExpiryWarnings = []time.Duration{time.Hour, 10 * time.Minute}
+
+// expiryTime works out when a Dask is due to be deleted, if ever
+func expiryTime(dask *analyticsv1.Dask) *time.Time {
+	var expires *time.Time
+	if dask.Spec.TTLSecondsAfterCreation != nil {
+		t := dask.CreationTimestamp.Add(time.Duration(*dask.Spec.TTLSecondsAfterCreation) * time.Second)
+		expires = &t
+	}
+	if dask.Spec.ExpiresAt != nil && (expires == nil || dask.Spec.ExpiresAt.Time.Before(*expires)) {
+		t := dask.Spec.ExpiresAt.Time
+		expires = &t
+	}
+	if expires != nil && dask.Status.LeaseExtension != nil {
+		t := expires.Add(dask.Status.LeaseExtension.Duration)
+		expires = &t
+	}
+	return expires
+}
+
+// extendLease takes up a request to extend the lease on a Dask.  The
+// extension is recorded in the status, along with a hash of the request,
+// before the annotation is removed - should removing it fail, the retry
+// finds the hash and does not count the extension twice.  This must run
+// before any other changes are made to the status.
+func (r *DaskReconciler) extendLease(ctx context.Context, dask *analyticsv1.Dask, log logr.Logger) error {
+	value, ok := dask.Annotations[analyticsv1.DaskExtendLeaseAnnotation]
+	if !ok {
+		dask.Status.LeaseExtensionApplied = ""
+		return nil
+	}
+	applied := fmt.Sprintf("%x", sha256.Sum256([]byte(value)))[:16]
+	if dask.Status.LeaseExtensionApplied != applied {
+		extension, err := time.ParseDuration(value)
+		if err != nil || extension <= 0 {
+			r.Recorder.Eventf(dask, corev1.EventTypeWarning, "InvalidLease", "Ignoring %s=%q: must be a positive duration eg: 2h", analyticsv1.DaskExtendLeaseAnnotation, value)
+		} else {
+			if dask.Status.LeaseExtension != nil {
+				extension += dask.Status.LeaseExtension.Duration
+			}
+			dask.Status.LeaseExtension = &metav1.Duration{Duration: extension}
+			dask.Status.ExpiryWarned = nil
+		}
+		dask.Status.LeaseExtensionApplied = applied
+		if err := r.Status().Update(ctx, dask); err != nil {
+			return err
+		}
+		if extension > 0 {
+			Infof(log, "Lease extended by %s", value)
+			r.Recorder.Eventf(dask, corev1.EventTypeNormal, "LeaseExtended", "Lease extended by %s", value)
+		}
+	}
+
+	delete(dask.Annotations, analyticsv1.DaskExtendLeaseAnnotation)
+	if err := r.Update(ctx, dask); err != nil {
+		return err
+	}
+	dask.Status.LeaseExtensionApplied = ""
+	return nil
+}
+
+// expiry deletes a Dask that has outlived its lease, and otherwise warns
+// of the coming expiry at the lead times.  It reports whether the Dask has
+// been deleted, and how long until the expiry next needs checking.
+func (r *DaskReconciler) expiry(ctx context.Context, dask *analyticsv1.Dask, log logr.Logger) (bool, time.Duration, error) {
+	if err := r.extendLease(ctx, dask, log); err != nil {
+		return false, 0, err
+	}
+
+	expires := expiryTime(dask)
+	if expires == nil {
+		dask.Status.ExpiresAt = nil
+		dask.Status.ExpiryWarned = nil
+		return false, 0, nil
+	}
+	dask.Status.ExpiresAt = &metav1.Time{Time: *expires}
+
+	remaining := time.Until(*expires)
+	if remaining <= 0 {
+		Infof(log, "Deleting expired Dask")
+		r.Recorder.Eventf(dask, corev1.EventTypeNormal, "Expired", "Lease expired at %s, deleting", expires.UTC().Format(time.RFC3339))
+		if err := r.Delete(ctx, dask); err != nil {
+			return false, 0, client.IgnoreNotFound(err)
+		}
+		return true, 0, nil
+	}
+
+	leads := defaultExpiryWarnings
+	if len(dask.Spec.ExpiryWarnings) > 0 {
+		leads = nil
+		for _, warning := range dask.Spec.ExpiryWarnings {
+			leads = append(leads, warning.Duration)
+		}
+	}
+	sort.Slice(leads, func(i, j int) bool { return leads[i] < leads[j] })
+
+	// warn once for the shortest lead time that has been reached, and
+	// look again at the next lead time, or the expiry
+	next := remaining
+	for _, lead := range leads {
+		if remaining <= lead {
+			if dask.Status.ExpiryWarned == nil || lead < dask.Status.ExpiryWarned.Duration {
+				dask.Status.ExpiryWarned = &metav1.Duration{Duration: lead}
+				r.Recorder.Eventf(dask, corev1.EventTypeWarning, "Expiring",
+					"Dask will be deleted at %s (in %s) - annotate with %s=<duration> to extend the lease",
+					expires.UTC().Format(time.RFC3339), remaining.Round(time.Second), analyticsv1.DaskExtendLeaseAnnotation)
+			}
+			break
+		}
+		next = remaining - lead
+	}
+	return false, next, nil
+}
@@ -0,0 +1,104 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+)
+
+var _ = Context("Inside of a new namespace with expiring clusters", func() {
+	ctx := context.TODO()
+	ns := SetupTest(ctx)
+
+	Describe("when a time-to-live is set", func() {
+
+		It("should delete the Dask once the lease is up", func() {
+			name := resource_name + "ttl"
+			ttl := int64(3)
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Image:                   "piersharding/arl-dask:latest",
+					ImagePullPolicy:         "IfNotPresent",
+					TTLSecondsAfterCreation: &ttl,
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			daskKey := client.ObjectKey{Name: name, Namespace: ns.Name}
+			Eventually(func() bool {
+				_ = k8sClient.Get(ctx, daskKey, dask)
+				return dask.Status.ExpiresAt != nil
+			}, time.Second*5, time.Millisecond*500).Should(BeTrue(), "expected the expiry to be reported")
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, daskKey, &analyticsv1.Dask{})
+				return apierrors.IsNotFound(err)
+			}, time.Second*10, time.Millisecond*500).Should(BeTrue(), "expected the expired Dask to be deleted")
+		})
+
+		It("should push back the expiry when the lease is extended", func() {
+			name := resource_name + "lease"
+			expiresAt := metav1.NewTime(time.Now().Add(time.Hour).Truncate(time.Second))
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					ExpiresAt:       &expiresAt,
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			daskKey := client.ObjectKey{Name: name, Namespace: ns.Name}
+			Eventually(func() bool {
+				_ = k8sClient.Get(ctx, daskKey, dask)
+				return dask.Status.ExpiresAt != nil && dask.Status.ExpiryWarned != nil
+			}, time.Second*5, time.Millisecond*500).Should(BeTrue(), "expected the expiry to be warned of")
+			Expect(dask.Status.ExpiresAt.Time.Equal(expiresAt.Time)).To(BeTrue())
+
+			dask.Annotations = map[string]string{analyticsv1.DaskExtendLeaseAnnotation: "2h"}
+			err = k8sClient.Update(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to annotate Dask resource")
+
+			Eventually(func() bool {
+				_ = k8sClient.Get(ctx, daskKey, dask)
+				return dask.Status.ExpiresAt != nil && dask.Status.ExpiresAt.Time.Equal(expiresAt.Add(2*time.Hour))
+			}, time.Second*5, time.Millisecond*500).Should(BeTrue(), "expected the lease to be extended")
+			Expect(dask.Annotations).NotTo(HaveKey(analyticsv1.DaskExtendLeaseAnnotation))
+			Expect(dask.Status.ExpiryWarned).To(BeNil())
+		})
+	})
+})
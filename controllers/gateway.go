@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// gatewayGroup is the API group of the Gateway API
+const gatewayGroup = "gateway.networking.k8s.io"
+
+// gatewayAPIVersions are the versions of HTTPRoute that can be used, newest first
+var gatewayAPIVersions = []string{"v1", "v1beta1"}
+
+// discoverGatewayAPI finds the newest HTTPRoute version that the API server
+// has - none when the Gateway API CRDs are not installed
+func discoverGatewayAPI(mapper meta.RESTMapper) (string, error) {
+	for _, version := range gatewayAPIVersions {
+		_, err := mapper.RESTMapping(schema.GroupKind{Group: gatewayGroup, Kind: "HTTPRoute"}, version)
+		if err == nil {
+			return version, nil
+		}
+		if !meta.IsNoMatchError(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// newHTTPRoute gives an empty HTTPRoute of the version in use
+func (r *DaskReconciler) newHTTPRoute() *unstructured.Unstructured {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: r.gatewayAPIVersion, Kind: "HTTPRoute"})
+	return route
+}
+
+// httpRouteNames lists the HTTPRoutes that a Dask exposes
+func httpRouteNames(dcontext dtypes.DaskContext) []string {
+	names := []string{"dask-dashboard-" + dcontext.Name}
+	if dcontext.Jupyter {
+		names = append(names, "jupyter-notebook-"+dcontext.Name)
+	}
+	return names
+}
+
+// routeAccepted looks for the Accepted condition that the Gateway has set
+// on an HTTPRoute
+func routeAccepted(route *unstructured.Unstructured, dcontext dtypes.DaskContext) (bool, string) {
+	parents, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	for _, p := range parents {
+		parent, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(parent, "parentRef", "name")
+		namespace, _, _ := unstructured.NestedString(parent, "parentRef", "namespace")
+		if name != dcontext.GatewayName || (namespace != "" && namespace != dcontext.GatewayNamespace) {
+			continue
+		}
+		conditions, _, _ := unstructured.NestedSlice(parent, "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != "Accepted" {
+				continue
+			}
+			message, _ := condition["message"].(string)
+			return condition["status"] == string(metav1.ConditionTrue), message
+		}
+	}
+	return false, "not yet accepted"
+}
+
+// routesCondition works out whether the Gateway has accepted the HTTPRoutes
+func (r *DaskReconciler) routesCondition(ctx context.Context, dcontext dtypes.DaskContext) (bool, string, string, error) {
+	if r.gatewayAPIVersion == "" {
+		return false, "GatewayAPIMissing", "The Gateway API HTTPRoute CRD is not installed", nil
+	}
+	var waiting []string
+	for _, name := range httpRouteNames(dcontext) {
+		route := r.newHTTPRoute()
+		if err := r.Get(ctx, client.ObjectKey{Namespace: dcontext.Namespace, Name: name}, route); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return false, "", "", err
+			}
+			return false, "NotFound", fmt.Sprintf("HTTPRoute %s not found", name), nil
+		}
+		if accepted, message := routeAccepted(route, dcontext); !accepted {
+			waiting = append(waiting, fmt.Sprintf("%s: %s", name, message))
+		}
+	}
+	if len(waiting) > 0 {
+		return false, "Pending", fmt.Sprintf("Waiting for Gateway %s/%s to accept HTTPRoutes - %s", dcontext.GatewayNamespace, dcontext.GatewayName, strings.Join(waiting, "; ")), nil
+	}
+	return true, "Accepted", fmt.Sprintf("HTTPRoutes accepted by Gateway %s/%s", dcontext.GatewayNamespace, dcontext.GatewayName), nil
+}
+
+// gatewayCondition sets or clears the RoutesAccepted condition
+func (r *DaskReconciler) gatewayCondition(ctx context.Context, dask *analyticsv1.Dask, dcontext dtypes.DaskContext) error {
+	if !dcontext.Gateway {
+		meta.RemoveStatusCondition(&dask.Status.Conditions, analyticsv1.DaskRoutesAccepted)
+		return nil
+	}
+	ready, reason, message, err := r.routesCondition(ctx, dcontext)
+	if err != nil {
+		return err
+	}
+	setCondition(&dask.Status.Conditions, dask.Generation, analyticsv1.DaskRoutesAccepted, ready, reason, message)
+	return nil
+}
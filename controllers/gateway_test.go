@@ -0,0 +1,123 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+)
+
+var _ = Context("Inside of a new namespace with a Gateway", func() {
+	ctx := context.TODO()
+	ns := SetupTest(ctx)
+
+	newRoute := func() *unstructured.Unstructured {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"})
+		return route
+	}
+
+	Describe("when gateway is set", func() {
+
+		It("should create HTTPRoutes instead of an Ingress, and report their acceptance", func() {
+			name := resource_name + "gateway"
+			daskObjectKey := client.ObjectKey{Name: name, Namespace: ns.Name}
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Jupyter:         true,
+					Replicas:        &initialReplicas,
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					JupyterIngress:  "notebook.dask.local",
+					MonitorIngress:  "monitor.dask.local",
+					Gateway: &analyticsv1.DaskGatewaySpec{
+						ParentRef: analyticsv1.DaskGatewayParentRef{Name: "shared", Namespace: "gateways"},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			routes := map[string]string{
+				"jupyter-notebook-" + name: "notebook.dask.local",
+				"dask-dashboard-" + name:   "monitor.dask.local",
+			}
+			for routeName, hostname := range routes {
+				route := newRoute()
+				Eventually(func() error {
+					return k8sClient.Get(ctx, client.ObjectKey{Name: routeName, Namespace: ns.Name}, route)
+				}, time.Second*5, time.Millisecond*500).Should(Succeed(), "expected HTTPRoute %s", routeName)
+				Expect(metav1.IsControlledBy(route, dask)).To(BeTrue())
+				hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+				Expect(hostnames).To(Equal([]string{hostname}))
+				parents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+				Expect(parents).To(Equal([]interface{}{map[string]interface{}{"name": "shared", "namespace": "gateways"}}))
+			}
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: "dask-" + name, Namespace: ns.Name}, &networking.Ingress{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue(), "expected no Ingress")
+
+			getRoutesAcceptedFunc := func() metav1.ConditionStatus {
+				if err := k8sClient.Get(ctx, daskObjectKey, dask); err != nil {
+					return ""
+				}
+				condition := meta.FindStatusCondition(dask.Status.Conditions, analyticsv1.DaskRoutesAccepted)
+				if condition == nil {
+					return ""
+				}
+				return condition.Status
+			}
+			Eventually(getRoutesAcceptedFunc, time.Second*5, time.Millisecond*500).Should(Equal(metav1.ConditionFalse))
+			Expect(dask.Status.DashboardURL).To(Equal("http://monitor.dask.local/"))
+			Expect(dask.Status.JupyterURL).To(Equal("http://notebook.dask.local/"))
+
+			// the Gateway accepts the routes
+			for routeName := range routes {
+				route := newRoute()
+				Expect(k8sClient.Get(ctx, client.ObjectKey{Name: routeName, Namespace: ns.Name}, route)).To(Succeed())
+				Expect(unstructured.SetNestedSlice(route.Object, []interface{}{map[string]interface{}{
+					"parentRef":      map[string]interface{}{"name": "shared", "namespace": "gateways"},
+					"controllerName": "example.com/gateway-controller",
+					"conditions": []interface{}{map[string]interface{}{
+						"type":               "Accepted",
+						"status":             "True",
+						"reason":             "Accepted",
+						"message":            "Route is accepted",
+						"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+					}},
+				}}, "status", "parents")).To(Succeed())
+				Expect(k8sClient.Status().Update(ctx, route)).To(Succeed())
+			}
+			Eventually(getRoutesAcceptedFunc, time.Second*5, time.Millisecond*500).Should(Equal(metav1.ConditionTrue))
+		})
+	})
+})
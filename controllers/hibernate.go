@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	"gitlab.com/piersharding/dask-operator/scheduler"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// wakeUpDask asks the Dask controller to wake up a hibernated cluster
+func wakeUpDask(ctx context.Context, c client.Client, dask *analyticsv1.Dask) error {
+	patch := client.MergeFrom(dask.DeepCopy())
+	annotations := dask.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[analyticsv1.DaskWakeUpAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	dask.SetAnnotations(annotations)
+	return c.Patch(ctx, dask, patch)
+}
+
+// hibernation works out whether the cluster should be hibernated, because
+// the Scheduler has been idle for spec.idleTimeout, and handles requests
+// to wake it up again.  This must run before any other changes are made to
+// the status, as clearing the wake-up annotation updates the Dask.
+func (r *DaskReconciler) hibernation(ctx context.Context, dask *analyticsv1.Dask, dcontext dtypes.DaskContext, log logr.Logger) (bool, error) {
+	conditions := &dask.Status.Conditions
+	hibernated := meta.IsStatusConditionTrue(*conditions, analyticsv1.DaskHibernated)
+
+	// woken up by hand, or by a DaskJob
+	if _, ok := dask.Annotations[analyticsv1.DaskWakeUpAnnotation]; ok {
+		delete(dask.Annotations, analyticsv1.DaskWakeUpAnnotation)
+		if err := r.Update(ctx, dask); err != nil {
+			return hibernated, err
+		}
+		if hibernated {
+			Infof(log, "Waking up hibernated cluster")
+			r.Recorder.Event(dask, corev1.EventTypeNormal, "WokenUp", "Woken up from hibernation")
+			setCondition(conditions, dask.Generation, analyticsv1.DaskHibernated, false, "WokenUp", "Woken up from hibernation")
+		}
+		dask.Status.IdleSince = nil
+		return false, nil
+	}
+
+	if dask.Spec.IdleTimeout == nil {
+		meta.RemoveStatusCondition(conditions, analyticsv1.DaskHibernated)
+		dask.Status.IdleSince = nil
+		return false, nil
+	}
+	if hibernated {
+		return true, nil
+	}
+
+	load, err := scheduler.GetLoad(r.schedulerAddress(dcontext))
+	if err != nil {
+		Infof(log, "Scheduler load unavailable, unable to check for idleness: %s", err.Error())
+		// only complain once the Scheduler is up, and once per outage
+		previous := meta.FindStatusCondition(*conditions, analyticsv1.DaskHibernated)
+		if meta.IsStatusConditionTrue(*conditions, analyticsv1.DaskSchedulerReady) &&
+			(previous == nil || previous.Reason != "LoadUnavailable") {
+			r.Recorder.Eventf(dask, corev1.EventTypeWarning, "LoadUnavailable", "Unable to read the Scheduler load to check for idleness: %s", err.Error())
+		}
+		setCondition(conditions, dask.Generation, analyticsv1.DaskHibernated, false, "LoadUnavailable", "Unable to read the Scheduler load: "+err.Error())
+		return false, nil
+	}
+	if !load.Idle() {
+		dask.Status.IdleSince = nil
+		setCondition(conditions, dask.Generation, analyticsv1.DaskHibernated, false, "Active", "Scheduler has tasks or clients")
+		return false, nil
+	}
+
+	now := metav1.Now()
+	if dask.Status.IdleSince == nil {
+		dask.Status.IdleSince = &now
+	}
+	idle := now.Sub(dask.Status.IdleSince.Time)
+	if idle < dask.Spec.IdleTimeout.Duration {
+		setCondition(conditions, dask.Generation, analyticsv1.DaskHibernated, false, "Idle", "Scheduler idle for "+idle.Round(time.Second).String())
+		return false, nil
+	}
+	Infof(log, "Hibernating cluster, idle for %s", idle.Round(time.Second))
+	r.Recorder.Eventf(dask, corev1.EventTypeNormal, "Hibernated", "Hibernated after being idle for %s", idle.Round(time.Second))
+	setCondition(conditions, dask.Generation, analyticsv1.DaskHibernated, true, "Idle",
+		"Scheduler idle since "+dask.Status.IdleSince.UTC().Format(time.RFC3339)+" - set the "+analyticsv1.DaskWakeUpAnnotation+" annotation to wake up")
+	return true, nil
+}
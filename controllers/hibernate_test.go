@@ -0,0 +1,120 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+)
+
+var _ = Context("Inside of a new namespace with an idle stub Scheduler", func() {
+	ctx := context.TODO()
+	ns := SetupTest(ctx)
+
+	var stub *httptest.Server
+	var clients int32
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&clients, 1)
+		stub = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/json/counts.json":
+				fmt.Fprintf(w, `{"tasks": 0, "clients": %d}`, atomic.LoadInt32(&clients))
+			case "/json/identity.json":
+				fmt.Fprint(w, `{"type": "Scheduler", "id": "Scheduler-stub", "workers": {}}`)
+			default:
+				http.NotFound(w, req)
+			}
+		}))
+		schedulerURL = stub.URL
+	})
+
+	AfterEach(func() {
+		stub.Close()
+	})
+
+	Describe("when an idle timeout is set", func() {
+
+		It("should hibernate the idle cluster, and wake it up again", func() {
+			name := resource_name + "idle"
+			replicas := int32(2)
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Image:             "piersharding/arl-dask:latest",
+					ImagePullPolicy:   "IfNotPresent",
+					Jupyter:           true,
+					Replicas:          &replicas,
+					IdleTimeout:       &metav1.Duration{Duration: time.Second},
+					HibernateNotebook: true,
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			workerKey := client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}
+			notebookKey := client.ObjectKey{Name: "jupyter-notebook-" + name, Namespace: ns.Name}
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*10, time.Millisecond*500).
+				Should(Equal(int32(0)), "expected the idle workers to be scaled to zero")
+			Eventually(getDeploymentReplicasFunc(ctx, notebookKey),
+				time.Second*5, time.Millisecond*500).
+				Should(Equal(int32(0)), "expected the idle notebook to be stopped")
+
+			daskKey := client.ObjectKey{Name: name, Namespace: ns.Name}
+			Eventually(func() string {
+				_ = k8sClient.Get(ctx, daskKey, dask)
+				return dask.Status.State
+			}, time.Second*5, time.Millisecond*500).Should(Equal("Hibernated"))
+			Expect(meta.IsStatusConditionTrue(dask.Status.Conditions, analyticsv1.DaskHibernated)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(dask.Status.Conditions, analyticsv1.DaskReady)).To(BeFalse())
+
+			// a client connects, and the cluster is woken up
+			atomic.StoreInt32(&clients, 2)
+			dask.Annotations = map[string]string{analyticsv1.DaskWakeUpAnnotation: "now"}
+			err = k8sClient.Update(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to annotate Dask resource")
+
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*5, time.Millisecond*500).
+				Should(Equal(int32(2)), "expected the workers to be restored")
+			Eventually(getDeploymentReplicasFunc(ctx, notebookKey),
+				time.Second*5, time.Millisecond*500).
+				Should(Equal(int32(1)), "expected the notebook to be restored")
+			Eventually(func() bool {
+				_ = k8sClient.Get(ctx, daskKey, dask)
+				_, ok := dask.Annotations[analyticsv1.DaskWakeUpAnnotation]
+				return ok || meta.IsStatusConditionTrue(dask.Status.Conditions, analyticsv1.DaskHibernated)
+			}, time.Second*5, time.Millisecond*500).Should(BeFalse(), "expected the wake-up to be handled")
+		})
+	})
+})
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// jupyterAuthSecretName names the Secret generated for the Jupyter credentials
+func jupyterAuthSecretName(name string) string {
+	return "jupyter-auth-" + name
+}
+
+// randomSecret generates a random password or token
+func randomSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// reconcileJupyterAuth works out where the Jupyter Notebook credentials
+// come from, generating them into an owned Secret when no Secret is given.
+// Only the name of the Secret is recorded in the status.
+func (r *DaskReconciler) reconcileJupyterAuth(ctx context.Context, dask *analyticsv1.Dask, dcontext *dtypes.DaskContext, log logr.Logger) error {
+	auth := dask.Spec.JupyterAuth
+	if auth == nil {
+		auth = &analyticsv1.DaskJupyterAuthSpec{}
+	}
+	generated := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: jupyterAuthSecretName(dask.Name), Namespace: dask.Namespace}}
+
+	if !dcontext.Jupyter || auth.SecretKeyRef != nil {
+		if removed, err := removeResource(ctx, r.Client, dask, generated); err != nil {
+			return err
+		} else if removed {
+			r.Recorder.Eventf(dask, corev1.EventTypeNormal, "Deleted", "Deleted Jupyter auth Secret %q", generated.Name)
+		}
+	}
+	if !dcontext.Jupyter {
+		dask.Status.JupyterAuthSecret = ""
+		return nil
+	}
+
+	dcontext.JupyterAuthType = "password"
+	if auth.Type != "" {
+		dcontext.JupyterAuthType = auth.Type
+	}
+	dcontext.JupyterAuthHashed = auth.Hashed
+
+	// a Secret provided by the user
+	if ref := auth.SecretKeyRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: dask.Namespace, Name: ref.Name}, secret); err != nil {
+			if client.IgnoreNotFound(err) == nil && ref.Optional != nil && *ref.Optional {
+				Infof(log, "optional Jupyter auth Secret %s not found", ref.Name)
+			} else {
+				return fmt.Errorf("Jupyter auth Secret %s: %v", ref.Name, err)
+			}
+		} else if _, ok := secret.Data[ref.Key]; !ok {
+			return fmt.Errorf("Jupyter auth Secret %s has no key %s", ref.Name, ref.Key)
+		}
+		dcontext.JupyterAuthSecret = ref.Name
+		dcontext.JupyterAuthKey = ref.Key
+		dask.Status.JupyterAuthSecret = ref.Name
+		return nil
+	}
+
+	// or one that is generated - an existing value is kept, and the
+	// deprecated jupyterPassword only seeds a Secret that has none
+	key := dcontext.JupyterAuthType
+	secret, exists, err := r.getSecret(ctx, dask, generated.Name, "jupyter-auth")
+	if err != nil {
+		return err
+	}
+	value := string(secret.Data[key])
+	if value == "" && dcontext.JupyterPassword != "" && key == "password" {
+		value = dcontext.JupyterPassword
+		r.Recorder.Eventf(dask, corev1.EventTypeWarning, "Deprecated",
+			"Seeded Jupyter auth Secret %q from the deprecated jupyterPassword - remove it from the Dask, and change the password in the Secret", secret.Name)
+	}
+	if value == "" {
+		if value, err = randomSecret(); err != nil {
+			return err
+		}
+	}
+	if !exists || string(secret.Data[key]) != value || len(secret.Data) != 1 {
+		secret.Data = map[string][]byte{key: []byte(value)}
+		if err := r.writeSecret(ctx, dask, secret, exists); err != nil {
+			return err
+		}
+		if !exists {
+			r.Recorder.Eventf(dask, corev1.EventTypeNormal, "Created", "Created Jupyter auth Secret %q", secret.Name)
+		}
+	}
+	dcontext.JupyterAuthSecret = secret.Name
+	dcontext.JupyterAuthKey = key
+	dask.Status.JupyterAuthSecret = secret.Name
+	return nil
+}
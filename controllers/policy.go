@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// policyCondition reports whether a Dask, with the defaults of its class
+// filled in, keeps to the DaskPolicies of its namespace.  The webhook stops
+// new violations, so this catches the Dasks that were there before a policy
+// was, or that a class has since changed.
+func (r *DaskReconciler) policyCondition(ctx context.Context, dask *analyticsv1.Dask, class *analyticsv1.DaskClusterClass, dcontext dtypes.DaskContext) error {
+	policies, err := analyticsv1.PoliciesFor(ctx, r, dask.Namespace)
+	if err != nil {
+		return err
+	}
+	if len(policies) == 0 {
+		meta.RemoveStatusCondition(&dask.Status.Conditions, analyticsv1.DaskPolicyCompliant)
+		return nil
+	}
+	var dasks analyticsv1.DaskList
+	if err := r.List(ctx, &dasks, client.InNamespace(dask.Namespace)); err != nil {
+		return err
+	}
+
+	merged := analyticsv1.ApplyClass(*dask, class)
+	merged.Spec.Image = dcontext.Image
+	var violations []string
+	for i := range policies {
+		policy := &policies[i]
+		errs := policy.ValidateDask(&merged, dasks.Items, false)
+		if dask.Spec.Jupyter {
+			if err := policy.ValidateImage(dcontext.JupyterImage, field.NewPath("spec").Child("jupyterImage")); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, err := range errs {
+			violations = append(violations, err.Error())
+		}
+	}
+
+	if len(violations) == 0 {
+		setCondition(&dask.Status.Conditions, dask.Generation, analyticsv1.DaskPolicyCompliant, true, "Compliant", fmt.Sprintf("keeps to %d DaskPolicies", len(policies)))
+		return nil
+	}
+	message := strings.Join(violations, "; ")
+	if !meta.IsStatusConditionFalse(dask.Status.Conditions, analyticsv1.DaskPolicyCompliant) {
+		r.Recorder.Event(dask, corev1.EventTypeWarning, "PolicyViolation", message)
+	}
+	setCondition(&dask.Status.Conditions, dask.Generation, analyticsv1.DaskPolicyCompliant, false, "PolicyViolation", message)
+	return nil
+}
+
+// policyDasks maps a change to a DaskPolicy on to all of the Dasks, as the
+// namespaces it selects may have changed along with it
+func (r *DaskReconciler) policyDasks(object client.Object) []reconcile.Request {
+	ctx := context.Background()
+	var dasks analyticsv1.DaskList
+	if err := r.List(ctx, &dasks); err != nil {
+		Errorf(r.Log, err, "unable to list the Dasks of DaskPolicy %s", object.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, dask := range dasks.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Namespace: dask.Namespace, Name: dask.Name}})
+	}
+	return requests
+}
@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	"gitlab.com/piersharding/dask-operator/scheduler"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// daskFinalizer holds back the deletion of a Dask until its workers have
+// been retired
+const daskFinalizer = "analytics.piersharding.com/retire-workers"
+
+// podDeletionCostAnnotation steers the ReplicaSet controller to remove the
+// retired workers first when a worker Deployment is scaled down
+const podDeletionCostAnnotation = "controller.kubernetes.io/pod-deletion-cost"
+
+// retiredDeletionCost is the cost given to retired workers
+const retiredDeletionCost = "-1000"
+
+// retirePollInterval is how often a retirement still going on at the
+// Scheduler is checked on
+var retirePollInterval = 5 * time.Second
+
+// retireLimit is how long workers are held back for the Scheduler to
+// retire them, before they are removed anyway
+var retireLimit = 2 * time.Minute
+
+// workerPods lists the running Pods of one of the worker Deployments or
+// StatefulSets - group is empty for the default workers
+func (r *DaskReconciler) workerPods(ctx context.Context, dcontext dtypes.DaskContext, group string, all bool) ([]corev1.Pod, error) {
+	var pods corev1.PodList
+	labels := client.MatchingLabels{
+		"app.kubernetes.io/name":     "dask-worker",
+		"app.kubernetes.io/instance": dcontext.Name,
+	}
+	if err := r.List(ctx, &pods, client.InNamespace(dcontext.Namespace), labels); err != nil {
+		return nil, err
+	}
+	var out []corev1.Pod
+	for _, pod := range pods.Items {
+		if !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if all || pod.Labels[workerGroupLabel] == group {
+			out = append(out, pod)
+		}
+	}
+	return out, nil
+}
+
+// podReady checks the Ready condition of a Pod
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// schedulerWorkers matches worker Pods to the workers known to the
+// Scheduler - workers are named after the uid of their Pod, or the Pod
+// itself in a StatefulSet, and fall back to the Pod IP as the host
+func schedulerWorkers(pods []corev1.Pod, identity *scheduler.Identity) map[string]string {
+	addresses := map[string]string{}
+	if identity == nil {
+		return addresses
+	}
+	for address, worker := range identity.Workers {
+		for _, pod := range pods {
+			if name, ok := worker.Name.(string); (ok && (name == string(pod.UID) || name == pod.Name)) ||
+				(pod.Status.PodIP != "" && worker.Host == pod.Status.PodIP) {
+				addresses[pod.Name] = address
+			}
+		}
+	}
+	return addresses
+}
+
+// chooseRetirees picks the count worker Pods that are cheapest to lose:
+// those that are not ready, then those the Scheduler does not know about,
+// then those holding the least data, and then the newest
+func chooseRetirees(pods []corev1.Pod, addresses map[string]string, identity *scheduler.Identity, count int) []corev1.Pod {
+	memory := func(pod *corev1.Pod) int64 {
+		if identity == nil {
+			return 0
+		}
+		return identity.Workers[addresses[pod.Name]].Metrics.Memory
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		a, b := &pods[i], &pods[j]
+		if podReady(a) != podReady(b) {
+			return !podReady(a)
+		}
+		_, aknown := addresses[a.Name]
+		_, bknown := addresses[b.Name]
+		if aknown != bknown {
+			return !aknown
+		}
+		if memory(a) != memory(b) {
+			return memory(a) < memory(b)
+		}
+		return b.CreationTimestamp.Before(&a.CreationTimestamp)
+	})
+	if count > len(pods) {
+		count = len(pods)
+	}
+	return pods[:count]
+}
+
+// highestOrdinals picks the worker Pods of a StatefulSet that scaling it
+// down to replicas removes - those from the highest ordinal down
+func highestOrdinals(pods []corev1.Pod, replicas int32) []corev1.Pod {
+	var out []corev1.Pod
+	for _, pod := range pods {
+		ordinal, err := strconv.Atoi(pod.Name[strings.LastIndex(pod.Name, "-")+1:])
+		if err == nil && int32(ordinal) >= replicas {
+			out = append(out, pod)
+		}
+	}
+	return out
+}
+
+// retireWorkers asks the Scheduler to retire the given worker Pods, so
+// that the data they hold is moved on to the remaining workers.  It reports
+// whether the Scheduler is still at it, so that the Pods can be held back
+// until it is done.  This is best effort - when the Scheduler cannot be
+// reached, or takes longer than the retireLimit, the Pods are lost as they
+// would have been anyway.
+func (r *DaskReconciler) retireWorkers(dask *analyticsv1.Dask, dcontext dtypes.DaskContext, pods []corev1.Pod, identity *scheduler.Identity, addresses map[string]string, log logr.Logger) bool {
+	if identity == nil {
+		return false
+	}
+	key := func(address string) string {
+		return dask.Namespace + "/" + dask.Name + "/" + address
+	}
+	// workers that are gone from the Scheduler have been retired
+	r.retiring.Range(func(k, _ interface{}) bool {
+		prefix := key("")
+		if name := k.(string); strings.HasPrefix(name, prefix) {
+			if _, ok := identity.Workers[strings.TrimPrefix(name, prefix)]; !ok {
+				r.retiring.Delete(k)
+			}
+		}
+		return true
+	})
+
+	var workers, names []string
+	pending := false
+	for _, pod := range pods {
+		address, ok := addresses[pod.Name]
+		if !ok {
+			continue
+		}
+		if since, ok := r.retiring.Load(key(address)); ok {
+			if time.Since(since.(time.Time)) < retireLimit {
+				pending = true
+			} else {
+				Infof(log, "gave up waiting on the Scheduler to retire worker %s", pod.Name)
+			}
+			continue
+		}
+		workers = append(workers, address)
+		names = append(names, pod.Name)
+	}
+	if len(workers) == 0 {
+		return pending
+	}
+	done, err := scheduler.RetireWorkers(r.schedulerAddress(dcontext), workers)
+	if err != nil {
+		Infof(log, "unable to retire workers %v: %s", workers, err.Error())
+		return pending
+	}
+	if !done {
+		now := time.Now()
+		for _, address := range workers {
+			r.retiring.Store(key(address), now)
+		}
+		r.Recorder.Eventf(dask, corev1.EventTypeNormal, "Retiring", "Retiring workers: %s", strings.Join(names, ", "))
+		return true
+	}
+	r.Recorder.Eventf(dask, corev1.EventTypeNormal, "Retired", "Retired workers: %s", strings.Join(names, ", "))
+	return pending
+}
+
+// scaleDownWorkers retires the workers that lowering the replicas of a
+// worker Deployment or StatefulSet will remove.  The Pods of a Deployment
+// are marked to be removed first, whereas a StatefulSet always removes
+// those with the highest ordinals.  While the Scheduler is still retiring
+// workers, their Deployment or StatefulSet is held at its current replicas.
+func (r *DaskReconciler) scaleDownWorkers(ctx context.Context, dask *analyticsv1.Dask, dcontext *dtypes.DaskContext, workerSets []client.Object, log logr.Logger) (bool, error) {
+	desired := map[string]int32{"": dcontext.Replicas}
+	for _, group := range dcontext.WorkerGroups {
+		desired[group.Name] = dcontext.ForWorkerGroup(group).Replicas
+	}
+
+	var identity *scheduler.Identity
+	retiring := false
+	for _, workerSet := range workerSets {
+		var current *int32
+		ordered := false
+		switch set := workerSet.(type) {
+		case *appsv1.Deployment:
+			current = set.Spec.Replicas
+		case *appsv1.StatefulSet:
+			current, ordered = set.Spec.Replicas, true
+		}
+		if workerSet.GetLabels()["app.kubernetes.io/name"] != "dask-worker" || current == nil {
+			continue
+		}
+		group := workerSet.GetLabels()[workerGroupLabel]
+		replicas, ok := desired[group]
+		if !ok || replicas >= *current {
+			continue
+		}
+
+		pods, err := r.workerPods(ctx, *dcontext, group, false)
+		if err != nil {
+			return false, err
+		}
+		if len(pods) == 0 {
+			continue
+		}
+		if identity == nil {
+			identity, err = scheduler.GetIdentity(r.schedulerAddress(*dcontext))
+			if err != nil {
+				Infof(log, "Scheduler unavailable, scaling down without retiring workers: %s", err.Error())
+			}
+		}
+		addresses := schedulerWorkers(pods, identity)
+		var retirees []corev1.Pod
+		if ordered {
+			retirees = highestOrdinals(pods, replicas)
+		} else {
+			count := len(pods) - int(replicas)
+			if count <= 0 {
+				continue
+			}
+			retirees = chooseRetirees(pods, addresses, identity, count)
+		}
+		if r.retireWorkers(dask, *dcontext, retirees, identity, addresses, log) {
+			Infof(log, "Holding %s at %d workers while they are retired", workerSet.GetName(), *current)
+			holdReplicas(dcontext, group, *current)
+			retiring = true
+		} else {
+			Infof(log, "Scaling %s from %d to %d workers", workerSet.GetName(), *current, replicas)
+		}
+		if ordered {
+			continue
+		}
+
+		for i := range retirees {
+			pod := &retirees[i]
+			patch := client.MergeFrom(pod.DeepCopy())
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations[podDeletionCostAnnotation] = retiredDeletionCost
+			if err := r.Patch(ctx, pod, patch); err != nil {
+				return retiring, client.IgnoreNotFound(err)
+			}
+		}
+	}
+	return retiring, nil
+}
+
+// holdReplicas keeps one of the worker Deployments or StatefulSets at the
+// given replicas - group is empty for the default workers
+func holdReplicas(dcontext *dtypes.DaskContext, group string, replicas int32) {
+	if group == "" {
+		dcontext.Replicas = replicas
+		return
+	}
+	held := map[string]int32{group: replicas}
+	for name, count := range dcontext.HeldReplicas {
+		if name != group {
+			held[name] = count
+		}
+	}
+	dcontext.HeldReplicas = held
+}
+
+// finalizeDask retires all of the workers of a Dask that is being deleted,
+// and then lets the deletion go ahead
+func (r *DaskReconciler) finalizeDask(ctx context.Context, dask *analyticsv1.Dask, log logr.Logger) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(dask, daskFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	// the same configuration as the Dask was reconciled with - a missing
+	// class is no reason to hold up the deletion
+	class, err := analyticsv1.ClassFor(ctx, r, dask)
+	if err != nil {
+		Infof(log, "deleting without the DaskClusterClass: %s", err.Error())
+	}
+	dcontext := dtypes.SetClassConfig(*dask, class)
+	pods, err := r.workerPods(ctx, dcontext, "", true)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(pods) > 0 {
+		identity, err := scheduler.GetIdentity(r.schedulerAddress(dcontext))
+		if err != nil {
+			Infof(log, "Scheduler unavailable, deleting without retiring workers: %s", err.Error())
+		}
+		if r.retireWorkers(dask, dcontext, pods, identity, schedulerWorkers(pods, identity), log) {
+			return ctrl.Result{RequeueAfter: retirePollInterval}, nil
+		}
+	}
+
+	controllerutil.RemoveFinalizer(dask, daskFinalizer)
+	if err := r.Update(ctx, dask); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, nil
+}
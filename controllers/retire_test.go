@@ -0,0 +1,345 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+)
+
+var _ = Context("Inside of a new namespace with a stub Scheduler API", func() {
+	ctx := context.TODO()
+	ns := SetupTest(ctx)
+
+	var stub *httptest.Server
+	var lock sync.Mutex
+	var identity string
+	var retired []string
+	var retireDelay time.Duration
+
+	retiredFunc := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, retired...)
+	}
+
+	BeforeEach(func() {
+		identity = `{"type": "Scheduler", "id": "Scheduler-stub", "workers": {}}`
+		retired = nil
+		retireDelay = 0
+		stub = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			lock.Lock()
+			delay := retireDelay
+			lock.Unlock()
+			if req.URL.Path == "/api/v1/retire_workers" {
+				// a Scheduler still moving the data of the workers
+				time.Sleep(delay)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			switch req.URL.Path {
+			case "/json/identity.json":
+				fmt.Fprint(w, identity)
+			case "/api/v1/retire_workers":
+				body := struct {
+					Workers []string `json:"workers"`
+				}{}
+				if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				retired = append(retired, body.Workers...)
+				fmt.Fprint(w, `{}`)
+			default:
+				http.NotFound(w, req)
+			}
+		}))
+		schedulerURL = stub.URL
+	})
+
+	AfterEach(func() {
+		stub.Close()
+	})
+
+	Describe("when the workers are scaled down and the Dask deleted", func() {
+
+		It("should retire the workers through the Scheduler first", func() {
+			name := resource_name + "retire"
+			replicas := int32(3)
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Replicas:        &replicas,
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			workerKey := client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*5, time.Millisecond*500).
+				Should(Equal(int32(3)), "expected the workers to be created")
+
+			// there is no ReplicaSet controller, so stand in the worker Pods,
+			// and tell the Scheduler about them - the least loaded go first
+			var pods []*core.Pod
+			workers := map[string]interface{}{}
+			for i := 0; i < 3; i++ {
+				pod := &core.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("dask-worker-%s-%d", name, i),
+						Namespace: ns.Name,
+						Labels: map[string]string{
+							"app.kubernetes.io/name":     "dask-worker",
+							"app.kubernetes.io/instance": name,
+						},
+					},
+					Spec: core.PodSpec{
+						Containers: []core.Container{{Name: "worker", Image: "piersharding/arl-dask:latest"}},
+					},
+				}
+				err := k8sClient.Create(ctx, pod)
+				Expect(err).NotTo(HaveOccurred(), "failed to create worker Pod")
+				pods = append(pods, pod)
+				workers[fmt.Sprintf("tcp://10.0.0.%d:8788", i)] = map[string]interface{}{
+					"name":    string(pod.UID),
+					"metrics": map[string]interface{}{"memory": 1000 * (3 - i)},
+				}
+			}
+			byt, _ := json.Marshal(map[string]interface{}{"type": "Scheduler", "workers": workers})
+			lock.Lock()
+			identity = string(byt)
+			lock.Unlock()
+
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
+			Expect(dask.Finalizers).To(ContainElement(daskFinalizer))
+			replicas = 1
+			dask.Spec.Replicas = &replicas
+			err = k8sClient.Update(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to scale down Dask resource")
+
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*5, time.Millisecond*500).
+				Should(Equal(int32(1)), "expected the workers to be scaled down")
+			Expect(retiredFunc()).To(ConsistOf("tcp://10.0.0.1:8788", "tcp://10.0.0.2:8788"))
+			for i, pod := range pods {
+				err := k8sClient.Get(ctx, client.ObjectKey{Name: pod.Name, Namespace: ns.Name}, pod)
+				Expect(err).NotTo(HaveOccurred(), "failed to retrieve worker Pod")
+				if i == 0 {
+					Expect(pod.Annotations).NotTo(HaveKey(podDeletionCostAnnotation))
+				} else {
+					Expect(pod.Annotations).To(HaveKeyWithValue(podDeletionCostAnnotation, retiredDeletionCost))
+				}
+			}
+
+			err = k8sClient.Delete(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to delete Dask resource")
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, &analyticsv1.Dask{})
+				return apierrors.IsNotFound(err)
+			}, time.Second*5, time.Millisecond*500).Should(BeTrue(), "expected the Dask to be deleted")
+			Expect(retiredFunc()).To(ContainElement("tcp://10.0.0.0:8788"))
+		})
+
+		It("should retire the highest ordinals of StatefulSet workers, whatever their load", func() {
+			name := resource_name + "retiress"
+			replicas := int32(3)
+			storageClass := "fast"
+			size := resource.MustParse("20Gi")
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Replicas:        &replicas,
+					Worker: &analyticsv1.DaskWorkerSpec{
+						Kind:    "StatefulSet",
+						Storage: &analyticsv1.DaskWorkerStorageSpec{StorageClassName: &storageClass, Size: &size},
+					},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			workerKey := client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}
+			statefulset := &apps.StatefulSet{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, workerKey, statefulset)
+			}, time.Second*5, time.Millisecond*500).Should(Succeed())
+			Expect(*statefulset.Spec.Replicas).To(Equal(int32(3)))
+			Expect(statefulset.Spec.ServiceName).To(Equal(workerKey.Name))
+			Expect(statefulset.Spec.VolumeClaimTemplates).To(HaveLen(1))
+			claim := statefulset.Spec.VolumeClaimTemplates[0]
+			Expect(claim.Name).To(Equal("localdir"))
+			Expect(*claim.Spec.StorageClassName).To(Equal("fast"))
+			Expect(claim.Spec.Resources.Requests.Storage().String()).To(Equal("20Gi"))
+			for _, volume := range statefulset.Spec.Template.Spec.Volumes {
+				Expect(volume.Name).NotTo(Equal("localdir"))
+			}
+			service := &core.Service{}
+			Expect(k8sClient.Get(ctx, workerKey, service)).To(Succeed())
+			Expect(service.Spec.ClusterIP).To(Equal(core.ClusterIPNone))
+			Expect(k8sClient.Get(ctx, workerKey, &apps.Deployment{})).NotTo(Succeed())
+
+			// the workers are named after their Pods, and the Pods with the
+			// highest ordinals go, even though they hold the least data
+			workers := map[string]interface{}{}
+			for i := 0; i < 3; i++ {
+				pod := &core.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("dask-worker-%s-%d", name, i),
+						Namespace: ns.Name,
+						Labels: map[string]string{
+							"app.kubernetes.io/name":     "dask-worker",
+							"app.kubernetes.io/instance": name,
+						},
+					},
+					Spec: core.PodSpec{
+						Containers: []core.Container{{Name: "worker", Image: "piersharding/arl-dask:latest"}},
+					},
+				}
+				err := k8sClient.Create(ctx, pod)
+				Expect(err).NotTo(HaveOccurred(), "failed to create worker Pod")
+				workers[fmt.Sprintf("tcp://10.0.1.%d:8788", i)] = map[string]interface{}{
+					"name":    pod.Name,
+					"metrics": map[string]interface{}{"memory": 1000 * (3 - i)},
+				}
+			}
+			byt, _ := json.Marshal(map[string]interface{}{"type": "Scheduler", "workers": workers})
+			lock.Lock()
+			identity = string(byt)
+			lock.Unlock()
+
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
+			replicas = 1
+			dask.Spec.Replicas = &replicas
+			err = k8sClient.Update(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to scale down Dask resource")
+
+			Eventually(func() int32 {
+				if err := k8sClient.Get(ctx, workerKey, statefulset); err != nil {
+					return -1
+				}
+				return *statefulset.Spec.Replicas
+			}, time.Second*5, time.Millisecond*500).Should(Equal(int32(1)), "expected the workers to be scaled down")
+			Expect(retiredFunc()).To(ConsistOf("tcp://10.0.1.1:8788", "tcp://10.0.1.2:8788"))
+		})
+
+		It("should hold the workers while the Scheduler is still retiring them", func() {
+			name := resource_name + "retireslow"
+			replicas := int32(2)
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					Replicas:        &replicas,
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			workerKey := client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*5, time.Millisecond*500).
+				Should(Equal(int32(2)), "expected the workers to be created")
+
+			workers := map[string]interface{}{}
+			for i := 0; i < 2; i++ {
+				pod := &core.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("dask-worker-%s-%d", name, i),
+						Namespace: ns.Name,
+						Labels: map[string]string{
+							"app.kubernetes.io/name":     "dask-worker",
+							"app.kubernetes.io/instance": name,
+						},
+					},
+					Spec: core.PodSpec{
+						Containers: []core.Container{{Name: "worker", Image: "piersharding/arl-dask:latest"}},
+					},
+				}
+				err := k8sClient.Create(ctx, pod)
+				Expect(err).NotTo(HaveOccurred(), "failed to create worker Pod")
+				workers[fmt.Sprintf("tcp://10.0.2.%d:8788", i)] = map[string]interface{}{
+					"name":    string(pod.UID),
+					"metrics": map[string]interface{}{"memory": 1000 * (2 - i)},
+				}
+			}
+			byt, _ := json.Marshal(map[string]interface{}{"type": "Scheduler", "workers": workers})
+			lock.Lock()
+			identity = string(byt)
+			retireDelay = time.Second
+			lock.Unlock()
+
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
+			replicas = 1
+			dask.Spec.Replicas = &replicas
+			err = k8sClient.Update(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to scale down Dask resource")
+
+			// the request times out, and the workers are held while the
+			// Scheduler still knows the retiring worker
+			Eventually(retiredFunc, time.Second*5, time.Millisecond*100).Should(ContainElement("tcp://10.0.2.1:8788"))
+			Consistently(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*2, time.Millisecond*250).
+				Should(Equal(int32(2)), "expected the workers to be held while they are retired")
+
+			// once the Scheduler has let the worker go, the scale down goes ahead
+			delete(workers, "tcp://10.0.2.1:8788")
+			byt, _ = json.Marshal(map[string]interface{}{"type": "Scheduler", "workers": workers})
+			lock.Lock()
+			identity = string(byt)
+			lock.Unlock()
+			Eventually(getDeploymentReplicasFunc(ctx, workerKey),
+				time.Second*5, time.Millisecond*500).
+				Should(Equal(int32(1)), "expected the workers to be scaled down")
+		})
+	})
+})
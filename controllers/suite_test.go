@@ -30,11 +30,13 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	"gitlab.com/piersharding/dask-operator/scheduler"
 	dtypes "gitlab.com/piersharding/dask-operator/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	// +kubebuilder:scaffold:imports
@@ -47,16 +49,19 @@ var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
 
+// schedulerURL points adaptive scaling at a stub Scheduler
+var schedulerURL string
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
 	RunSpecsWithDefaultAndCustomReporters(t,
 		"Controller Suite",
-		[]Reporter{envtest.NewlineReporter{}})
+		[]Reporter{printer.NewlineReporter{}})
 }
 
 var _ = BeforeSuite(func(done Done) {
-	logf.SetLogger(zap.LoggerTo(GinkgoWriter, true))
+	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
 	By("bootstrapping test environment")
 	t := true
@@ -66,7 +71,10 @@ var _ = BeforeSuite(func(done Done) {
 		}
 	} else {
 		testEnv = &envtest.Environment{
-			CRDDirectoryPaths: []string{filepath.Join("..", "config", "crd", "bases")},
+			CRDDirectoryPaths: []string{
+				filepath.Join("..", "config", "crd", "bases"),
+				filepath.Join("testdata", "crd"),
+			},
 		}
 	}
 
@@ -83,6 +91,11 @@ var _ = BeforeSuite(func(done Done) {
 
 	// +kubebuilder:scaffold:scheme
 
+	// poll the stub Scheduler quickly
+	schedulerPollInterval = time.Second
+	retirePollInterval = time.Millisecond * 500
+	scheduler.RetireTimeout = time.Millisecond * 200
+
 	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
 	Expect(err).ToNot(HaveOccurred())
 	Expect(k8sClient).ToNot(BeNil())
@@ -103,11 +116,12 @@ var _ = AfterSuite(func() {
 // * stopping the 'DaskReconciler" after the test ends
 // Call this function at the start of each of your tests.
 func SetupTest(ctx context.Context) *core.Namespace {
-	var stopCh chan struct{}
+	var stop context.CancelFunc
 	ns := &core.Namespace{}
 
 	BeforeEach(func() {
-		stopCh = make(chan struct{})
+		var mgrCtx context.Context
+		mgrCtx, stop = context.WithCancel(ctx)
 		*ns = core.Namespace{
 			ObjectMeta: metav1.ObjectMeta{Name: "testns-" + randStringRunes(5)},
 		}
@@ -124,6 +138,9 @@ func SetupTest(ctx context.Context) *core.Namespace {
 			CustomLog: dtypes.CustomLogger{Logger: ctrl.Log.WithName("controllers").WithName("Dask")},
 			Scheme:    mgr.GetScheme(),
 			Recorder:  mgr.GetEventRecorderFor("dask-controller"),
+			SchedulerAddress: func(dtypes.DaskContext) string {
+				return schedulerURL
+			},
 		}
 		err = daskcontroller.SetupWithManager(mgr)
 		Expect(err).NotTo(HaveOccurred(), "failed to setup controller")
@@ -139,13 +156,13 @@ func SetupTest(ctx context.Context) *core.Namespace {
 		Expect(err).NotTo(HaveOccurred(), "failed to setup job controller")
 
 		go func() {
-			err := mgr.Start(stopCh)
+			err := mgr.Start(mgrCtx)
 			Expect(err).NotTo(HaveOccurred(), "failed to start manager")
 		}()
 	})
 
 	AfterEach(func() {
-		close(stopCh)
+		stop()
 
 		err := k8sClient.Delete(ctx, ns)
 		Expect(err).NotTo(HaveOccurred(), "failed to delete test namespace")
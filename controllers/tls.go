@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	"gitlab.com/piersharding/dask-operator/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultCertificateDuration is how long issued certificates are valid for
+	defaultCertificateDuration = 90 * 24 * time.Hour
+	// defaultCertificateRenewBefore is how long before expiry they are reissued
+	defaultCertificateRenewBefore = 30 * 24 * time.Hour
+	// caDuration is how long the per-cluster CA is valid for
+	caDuration = 10 * 365 * 24 * time.Hour
+
+	caCertKey = "ca.crt"
+	caKeyKey  = "ca.key"
+)
+
+// tlsRoles are the roles that certificates are issued for - the notebook
+// and DaskJobs use the client certificate
+var tlsRoles = []string{"scheduler", "worker", "client"}
+
+// tlsSecretName names the Secret holding the certificate for a role
+func tlsSecretName(name string, role string) string {
+	return fmt.Sprintf("dask-tls-%s-%s", name, role)
+}
+
+// caSecretName names the Secret holding the CA for a Dask
+func caSecretName(name string) string {
+	return "dask-ca-" + name
+}
+
+// tlsDNSNames gives the names the Scheduler certificate is valid for
+func tlsDNSNames(dcontext dtypes.DaskContext, role string) []string {
+	if role != "scheduler" {
+		return []string{fmt.Sprintf("dask-%s-%s", role, dcontext.Name)}
+	}
+	service := "dask-scheduler-" + dcontext.Name
+	names := []string{
+		service,
+		service + "." + dcontext.Namespace,
+		service + "." + dcontext.Namespace + ".svc",
+		service + "." + dcontext.Namespace + ".svc.cluster.local",
+	}
+	if dcontext.SchedulerIngress != "" {
+		names = append(names, dcontext.SchedulerIngress)
+	}
+	return names
+}
+
+// expiring checks whether a PEM encoded certificate is unreadable or due
+// for renewal
+func expiring(certPEM []byte, renewBefore time.Duration) bool {
+	cert, err := utils.ParseCertificate(certPEM)
+	if err != nil {
+		return true
+	}
+	return time.Now().Add(renewBefore).After(cert.NotAfter)
+}
+
+// outdated checks whether a PEM encoded certificate is unreadable, due for
+// renewal, or issued for other names than those it should be valid for
+func outdated(certPEM []byte, renewBefore time.Duration, dnsNames []string) bool {
+	if expiring(certPEM, renewBefore) {
+		return true
+	}
+	cert, err := utils.ParseCertificate(certPEM)
+	if err != nil {
+		return true
+	}
+	have := append([]string(nil), cert.DNSNames...)
+	want := append([]string(nil), dnsNames...)
+	sort.Strings(have)
+	sort.Strings(want)
+	return !reflect.DeepEqual(have, want)
+}
+
+// reconcileTLS makes sure that the CA and the certificates for each role
+// exist, are not about to expire and are valid for the current hostnames,
+// reissuing them as needed.  It sets the
+// TLS version in the context, so that a rotation rolls the Pods, and
+// reports how long until the certificates next need renewing.
+func (r *DaskReconciler) reconcileTLS(ctx context.Context, dask *analyticsv1.Dask, dcontext *dtypes.DaskContext, log logr.Logger) (time.Duration, error) {
+	spec := dask.Spec.TLS
+	if spec == nil {
+		for _, name := range append([]string{caSecretName(dask.Name)}, roleSecretNames(dask.Name)...) {
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: dask.Namespace}}
+			if removed, err := removeResource(ctx, r.Client, dask, secret); err != nil {
+				return 0, err
+			} else if removed {
+				r.Recorder.Eventf(dask, corev1.EventTypeNormal, "Deleted", "Deleted TLS Secret %q", name)
+			}
+		}
+		dask.Status.CertificatesNotAfter = nil
+		return 0, nil
+	}
+	duration := defaultCertificateDuration
+	if spec.Duration != nil {
+		duration = spec.Duration.Duration
+	}
+	renewBefore := defaultCertificateRenewBefore
+	if spec.RenewBefore != nil {
+		renewBefore = spec.RenewBefore.Duration
+	}
+	if renewBefore >= duration {
+		// a short duration with the default renewal would never settle
+		renewBefore = duration / 3
+	}
+
+	// the CA
+	ca, exists, err := r.getSecret(ctx, dask, caSecretName(dask.Name), "dask-tls")
+	if err != nil {
+		return 0, err
+	}
+	if !exists || len(ca.Data[caKeyKey]) == 0 || expiring(ca.Data[caCertKey], renewBefore) {
+		certPEM, keyPEM, err := utils.NewCA(fmt.Sprintf("dask-ca-%s.%s", dask.Name, dask.Namespace), caDuration)
+		if err != nil {
+			return 0, err
+		}
+		ca.Data = map[string][]byte{caCertKey: certPEM, caKeyKey: keyPEM}
+		if err := r.writeSecret(ctx, dask, ca, exists); err != nil {
+			return 0, err
+		}
+		Infof(log, "Issued TLS CA %s", ca.Name)
+	}
+
+	// the certificate for each role
+	var issued []string
+	var notAfter time.Time
+	fingerprint := sha256.New()
+	for _, role := range tlsRoles {
+		secret, exists, err := r.getSecret(ctx, dask, tlsSecretName(dask.Name, role), "dask-tls")
+		if err != nil {
+			return 0, err
+		}
+		dnsNames := tlsDNSNames(*dcontext, role)
+		if !exists || !bytes.Equal(secret.Data[caCertKey], ca.Data[caCertKey]) || outdated(secret.Data[corev1.TLSCertKey], renewBefore, dnsNames) {
+			certPEM, keyPEM, err := utils.IssueCertificate(ca.Data[caCertKey], ca.Data[caKeyKey],
+				fmt.Sprintf("dask-%s-%s", role, dask.Name), dnsNames, duration)
+			if err != nil {
+				return 0, err
+			}
+			secret.Type = corev1.SecretTypeTLS
+			secret.Data = map[string][]byte{
+				caCertKey:               ca.Data[caCertKey],
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+			}
+			if err := r.writeSecret(ctx, dask, secret, exists); err != nil {
+				return 0, err
+			}
+			issued = append(issued, role)
+		}
+		cert, err := utils.ParseCertificate(secret.Data[corev1.TLSCertKey])
+		if err != nil {
+			return 0, err
+		}
+		if notAfter.IsZero() || cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+		fingerprint.Write(secret.Data[corev1.TLSCertKey])
+	}
+	if len(issued) > 0 {
+		Infof(log, "Issued TLS certificates for: %v", issued)
+		r.Recorder.Eventf(dask, corev1.EventTypeNormal, "CertificatesIssued", "Issued TLS certificates for: %v, valid until %s", issued, notAfter.UTC().Format(time.RFC3339))
+	}
+
+	dcontext.TLSVersion = fmt.Sprintf("%x", fingerprint.Sum(nil))[:16]
+	dask.Status.CertificatesNotAfter = &metav1.Time{Time: notAfter}
+	return time.Until(notAfter.Add(-renewBefore)), nil
+}
+
+// roleSecretNames lists the Secrets of the certificates for each role
+func roleSecretNames(name string) []string {
+	var names []string
+	for _, role := range tlsRoles {
+		names = append(names, tlsSecretName(name, role))
+	}
+	return names
+}
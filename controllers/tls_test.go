@@ -0,0 +1,128 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	"gitlab.com/piersharding/dask-operator/utils"
+)
+
+var _ = Context("Inside of a new namespace with TLS", func() {
+	ctx := context.TODO()
+	ns := SetupTest(ctx)
+
+	getTLSVersionFunc := func(key client.ObjectKey) func() string {
+		return func() string {
+			depl := &apps.Deployment{}
+			if err := k8sClient.Get(ctx, key, depl); err != nil {
+				return ""
+			}
+			return depl.Spec.Template.Annotations["analytics.piersharding.com/tls-version"]
+		}
+	}
+
+	Describe("when tls is enabled", func() {
+
+		It("should issue certificates from a per-cluster CA, and reissue lost ones", func() {
+			name := resource_name + "tls"
+			dask := &analyticsv1.Dask{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: ns.Name,
+				},
+				Spec: analyticsv1.DaskSpec{
+					Image:           "piersharding/arl-dask:latest",
+					ImagePullPolicy: "IfNotPresent",
+					TLS:             &analyticsv1.DaskTLSSpec{},
+				},
+			}
+
+			err := k8sClient.Create(ctx, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to create test Dask resource")
+
+			schedulerKey := client.ObjectKey{Name: "dask-scheduler-" + name, Namespace: ns.Name}
+			Eventually(getTLSVersionFunc(schedulerKey), time.Second*5, time.Millisecond*500).
+				ShouldNot(BeEmpty(), "expected the Scheduler Pods to carry the TLS version")
+			version := getTLSVersionFunc(schedulerKey)()
+
+			ca := &core.Secret{}
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: "dask-ca-" + name, Namespace: ns.Name}, ca)
+			Expect(err).NotTo(HaveOccurred(), "failed to get the CA Secret")
+			caCert, err := utils.ParseCertificate(ca.Data["ca.crt"])
+			Expect(err).NotTo(HaveOccurred())
+			roots := x509.NewCertPool()
+			roots.AddCert(caCert)
+
+			for _, role := range []string{"scheduler", "worker", "client"} {
+				secret := &core.Secret{}
+				err := k8sClient.Get(ctx, client.ObjectKey{Name: "dask-tls-" + name + "-" + role, Namespace: ns.Name}, secret)
+				Expect(err).NotTo(HaveOccurred(), "failed to get the %s certificate Secret", role)
+				Expect(metav1.IsControlledBy(secret, dask)).To(BeTrue())
+				Expect(secret.Data).NotTo(HaveKey("ca.key"))
+				cert, err := utils.ParseCertificate(secret.Data["tls.crt"])
+				Expect(err).NotTo(HaveOccurred())
+				opts := x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+				if role == "scheduler" {
+					opts.DNSName = "dask-scheduler-" + name + "." + ns.Name + ".svc"
+				}
+				_, err = cert.Verify(opts)
+				Expect(err).NotTo(HaveOccurred(), "expected the %s certificate to be signed by the CA", role)
+			}
+
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
+			Expect(dask.Status.SchedulerAddress).To(Equal("tls://dask-scheduler-" + name + "." + ns.Name + ":8786"))
+			Expect(dask.Status.CertificatesNotAfter).NotTo(BeNil())
+
+			// a lost certificate is reissued, and the Pods rolled on to it
+			err = k8sClient.Delete(ctx, &core.Secret{ObjectMeta: metav1.ObjectMeta{Name: "dask-tls-" + name + "-worker", Namespace: ns.Name}})
+			Expect(err).NotTo(HaveOccurred(), "failed to delete the worker certificate Secret")
+			Eventually(getTLSVersionFunc(client.ObjectKey{Name: "dask-worker-" + name, Namespace: ns.Name}),
+				time.Second*5, time.Millisecond*500).
+				ShouldNot(Equal(version), "expected the workers to be rolled on to the new certificate")
+
+			// a new Scheduler hostname gets a new Scheduler certificate
+			getSchedulerNamesFunc := func() []string {
+				secret := &core.Secret{}
+				if err := k8sClient.Get(ctx, client.ObjectKey{Name: "dask-tls-" + name + "-scheduler", Namespace: ns.Name}, secret); err != nil {
+					return nil
+				}
+				cert, err := utils.ParseCertificate(secret.Data["tls.crt"])
+				if err != nil {
+					return nil
+				}
+				return cert.DNSNames
+			}
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: ns.Name}, dask)
+			Expect(err).NotTo(HaveOccurred(), "failed to retrieve Dask resource")
+			dask.Spec.SchedulerIngress = "scheduler-" + name + ".dask.example.com"
+			Expect(k8sClient.Update(ctx, dask)).To(Succeed())
+			Eventually(getSchedulerNamesFunc, time.Second*5, time.Millisecond*500).
+				Should(ContainElement(dask.Spec.SchedulerIngress), "expected the Scheduler certificate to be reissued for the new hostname")
+		})
+	})
+})
@@ -2,18 +2,22 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/go-logr/logr"
 	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	"gitlab.com/piersharding/dask-operator/models"
 	dtypes "gitlab.com/piersharding/dask-operator/types"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -35,6 +39,14 @@ func Debugf(log logr.Logger, format string, a ...interface{}) {
 	log.Info(fmt.Sprintf(format, a...))
 }
 
+// objectName gives the name of a resource for reporting
+func objectName(obj client.Object) string {
+	if m, err := meta.Accessor(obj); err == nil {
+		return m.GetName()
+	}
+	return ""
+}
+
 // set ownership and reference
 func setOwnerReferences(res metav1.Object, cntlr metav1.Object, parentKind string, s *runtime.Scheme, t metav1.TypeMeta, log logr.Logger) error {
 
@@ -50,199 +62,400 @@ func setOwnerReferences(res metav1.Object, cntlr metav1.Object, parentKind strin
 	return nil
 }
 
-// read back the status info for the Ingress resource
-func (r *DaskReconciler) ingressStatus(dcontext dtypes.DaskContext) (string, error) {
-	ctx := context.Background()
+// setCondition records a condition against the given generation
+func setCondition(conditions *[]metav1.Condition, generation int64, conditionType string, ready bool, reason string, message string) {
+	status := metav1.ConditionFalse
+	if ready {
+		status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
 
-	objkey := client.ObjectKey{
-		Namespace: dcontext.Namespace,
-		Name:      "dask-" + dcontext.Name,
+// rolloutCondition works out whether a Deployment or StatefulSet is fully
+// available
+func rolloutCondition(kind string, name string, replicas *int32, ready int32, generation int64, observed int64) (bool, string, string) {
+	desired := int32(1)
+	if replicas != nil {
+		desired = *replicas
 	}
-
-	log := r.Log.WithValues("ingress", objkey)
-	if dcontext.JupyterIngress == "" && dcontext.SchedulerIngress == "" {
-		return "", nil
+	if observed < generation {
+		return false, "Progressing", fmt.Sprintf("%s %s rollout in progress", kind, name)
 	}
-	ingress := extv1beta1.Ingress{}
-	if err := r.Get(ctx, objkey, &ingress); err != nil {
-		Errorf(log, err, "ingressStatus.Get Error: %+v\n", err.Error())
-		return "", client.IgnoreNotFound(err)
+	message := fmt.Sprintf("%s %s has %d of %d replicas ready", kind, name, ready, desired)
+	if ready < desired {
+		return false, "Pending", message
 	}
-	owner := metav1.GetControllerOf(&ingress)
-	if owner == nil {
-		err := errors.New("ingressStatus.Get Error: owner empty")
-		log.Error(err, "ingressStatus.Get Error: owner empty", owner)
-		return "", err
-	}
-	// ...make sure it's a Dask...
-	if owner.APIVersion != daskApiGVStr || owner.Kind != "Dask" {
-		err := errors.New("ingressStatus.Get Error: wrong kind/owner")
-		log.Error(err, fmt.Sprintf("ingressStatus.Get Error: [%s] wrong kind/owner: %s/%s", daskApiGVStr, owner.Kind, owner.APIVersion))
-		return "", err
-	}
-
-	// ingress, err := ClientSet.ExtensionsV1beta1().Ingresses(dcontext.Namespace).Get("dask-"+dcontext.Name, metav1.GetOptions{})
-	// if err != nil {
-	// 	log.Errorf("ingressStatus.Get Error: %+v\n", err.Error())
-	// 	if !strings.Contains(err.Error(), "not found") {
-	// 		return "", err
-	// 	}
-	// }
-	Debugf(log, "The ingress: %+v\n", ingress)
-	ip := ""
-	for _, i := range ingress.Status.LoadBalancer.Ingress {
-		ip = ip + i.IP
-	}
-	var h []string
-	for _, r := range ingress.Spec.Rules {
-		h = append(h, fmt.Sprintf("http://%s/", r.Host))
+	return true, "Ready", message
+}
+
+// deploymentCondition works out whether a Deployment is fully available
+func deploymentCondition(deployment *appsv1.Deployment, name string) (bool, string, string) {
+	if deployment == nil {
+		return false, "NotFound", fmt.Sprintf("Deployment %s not found", name)
 	}
-	hosts := fmt.Sprintf("Ingress: %s IP: %s, Hosts: %s", ingress.Name, ip, strings.Join(h[:], ", "))
+	return rolloutCondition("Deployment", name, deployment.Spec.Replicas, deployment.Status.ReadyReplicas, deployment.Generation, deployment.Status.ObservedGeneration)
+}
 
-	status, err := json.Marshal(&ingress.Status)
-	// status, err := json.Marshal(&ingress)
-	if err != nil {
-		Errorf(log, err, "ingressStatus.json Error: %+v\n", err.Error())
-		return "", err
+// statefulSetCondition works out whether a StatefulSet is fully available
+func statefulSetCondition(statefulset *appsv1.StatefulSet, name string) (bool, string, string) {
+	if statefulset == nil {
+		return false, "NotFound", fmt.Sprintf("StatefulSet %s not found", name)
 	}
-	s := hosts + " status: " + string(status)
-	Debugf(log, "The ingress status: %s\n", s)
-	return s, nil
+	return rolloutCondition("StatefulSet", name, statefulset.Spec.Replicas, statefulset.Status.ReadyReplicas, statefulset.Generation, statefulset.Status.ObservedGeneration)
 }
 
-// read back the status info for the Service resource
-func (r *DaskReconciler) serviceStatus(dcontext dtypes.DaskContext, name string) (string, error) {
-	ctx := context.Background()
+// daskConditions tallies up the components of the cluster, and works out
+// the conditions, worker counts and endpoints for the status
+func (r *DaskReconciler) daskConditions(ctx context.Context, dask *analyticsv1.Dask, dcontext dtypes.DaskContext) error {
+	conditions := &dask.Status.Conditions
+	generation := dask.Generation
+	dask.Status.Components = 0
+	dask.Status.Succeeded = 0
+
+	// the Scheduler
+	name := "dask-scheduler-" + dask.Name
+	deployment, _ := r.getDeployment(dask.Namespace, name, dask)
+	ready, reason, message := deploymentCondition(deployment, name)
+	setCondition(conditions, generation, analyticsv1.DaskSchedulerReady, ready, reason, message)
+
+	// the workers, including any groups
+	names := []string{"dask-worker-" + dask.Name}
+	for _, group := range dcontext.WorkerGroups {
+		names = append(names, "dask-worker-"+dask.Name+"-"+group.Name)
+	}
+	dask.Status.Workers = 0
+	dask.Status.ReadyWorkers = 0
+	dask.Status.Replicas = 0
+	dask.Status.Selector = workerSelector(dask.Name)
+	workersReady, reason, message := true, "Ready", ""
+	for i, name := range names {
+		var replicas *int32
+		var current, readyReplicas int32
+		var ready bool
+		var why, detail string
+		if dcontext.WorkerStatefulSet {
+			statefulset, _ := r.getStatefulSet(dask.Namespace, name, dask)
+			if statefulset != nil {
+				replicas, current, readyReplicas = statefulset.Spec.Replicas, statefulset.Status.Replicas, statefulset.Status.ReadyReplicas
+			}
+			ready, why, detail = statefulSetCondition(statefulset, name)
+		} else {
+			deployment, _ := r.getDeployment(dask.Namespace, name, dask)
+			if deployment != nil {
+				replicas, current, readyReplicas = deployment.Spec.Replicas, deployment.Status.Replicas, deployment.Status.ReadyReplicas
+			}
+			ready, why, detail = deploymentCondition(deployment, name)
+		}
+		if i == 0 {
+			// the workers that spec.replicas scales
+			dask.Status.Replicas = current
+		}
+		if replicas != nil {
+			dask.Status.Workers += *replicas
+		}
+		dask.Status.ReadyWorkers += readyReplicas
+		if !ready && workersReady {
+			workersReady, reason, message = false, why, detail
+		}
+	}
+	if workersReady {
+		message = fmt.Sprintf("%d of %d workers ready", dask.Status.ReadyWorkers, dask.Status.Workers)
+	}
+	setCondition(conditions, generation, analyticsv1.DaskWorkersReady, workersReady, reason, message)
 
-	objkey := client.ObjectKey{
-		Namespace: dcontext.Namespace,
-		Name:      name,
+	// the Jupyter Notebook
+	if dcontext.Jupyter {
+		name := "jupyter-notebook-" + dask.Name
+		deployment, _ := r.getDeployment(dask.Namespace, name, dask)
+		ready, reason, message := deploymentCondition(deployment, name)
+		setCondition(conditions, generation, analyticsv1.DaskNotebookReady, ready, reason, message)
+	} else {
+		meta.RemoveStatusCondition(conditions, analyticsv1.DaskNotebookReady)
 	}
 
-	log := r.Log.WithValues("service", objkey)
+	// the Ingress, or the HTTPRoutes that replace it
+	if err := r.gatewayCondition(ctx, dask, dcontext); err != nil {
+		return err
+	}
+	if (dcontext.JupyterIngress != "" || dcontext.SchedulerIngress != "") && !dcontext.Gateway {
+		ready, reason, message, err := r.ingressCondition(ctx, dcontext)
+		if err != nil {
+			return err
+		}
+		setCondition(conditions, generation, analyticsv1.DaskIngressReady, ready, reason, message)
+	} else {
+		meta.RemoveStatusCondition(conditions, analyticsv1.DaskIngressReady)
+	}
+
+	// suspended by hand
+	if dcontext.Suspended {
+		setCondition(conditions, generation, analyticsv1.DaskSuspended, true, "Suspended", "spec.suspend is set")
+	} else {
+		meta.RemoveStatusCondition(conditions, analyticsv1.DaskSuspended)
+	}
+
+	// and the cluster as a whole
+	var pending []string
+	for _, condition := range *conditions {
+		switch condition.Type {
+		case analyticsv1.DaskReady, analyticsv1.DaskHibernated, analyticsv1.DaskSuspended:
+		default:
+			if condition.Status != metav1.ConditionTrue {
+				pending = append(pending, condition.Type)
+			}
+		}
+	}
+	if dcontext.Suspended {
+		dask.Status.State = "Suspended"
+		setCondition(conditions, generation, analyticsv1.DaskReady, false, "Suspended", "The cluster is suspended")
+	} else if dcontext.Hibernated {
+		dask.Status.State = "Hibernated"
+		setCondition(conditions, generation, analyticsv1.DaskReady, false, "Hibernated", "The cluster is hibernated")
+	} else if len(pending) == 0 {
+		dask.Status.State = "Running"
+		setCondition(conditions, generation, analyticsv1.DaskReady, true, "Ready", "All components are ready")
+	} else {
+		dask.Status.State = "Building"
+		setCondition(conditions, generation, analyticsv1.DaskReady, false, "ComponentsNotReady", "Waiting for: "+strings.Join(pending, ", "))
+	}
+
+	daskEndpoints(dask, dcontext)
+	return r.externalEndpoints(ctx, dask, dcontext)
+}
 
-	service := corev1.Service{}
-	if err := r.Get(ctx, objkey, &service); err != nil {
-		Errorf(log, err, "serviceStatus.Get Error: %+v\n", err.Error())
-		return "", client.IgnoreNotFound(err)
+// externalEndpoints fills in the addresses of the Scheduler and Notebook for
+// clients outside of the cluster, once their LoadBalancers have them
+func (r *DaskReconciler) externalEndpoints(ctx context.Context, dask *analyticsv1.Dask, dcontext dtypes.DaskContext) error {
+	address, err := r.serviceAddress(ctx, dask.Namespace, "dask-scheduler-"+dask.Name)
+	if err != nil {
+		return err
 	}
-	owner := metav1.GetControllerOf(&service)
-	if owner == nil {
-		err := errors.New("serviceStatus.Get Error: owner empty")
-		log.Error(err, "serviceStatus.Get Error: owner empty", owner)
-		return "", err
-	}
-	// ...make sure it's a Dask...
-	if owner.APIVersion != daskApiGVStr || owner.Kind != "Dask" {
-		err := errors.New("serviceStatus.Get Error: wrong kind/owner")
-		log.Error(err, "serviceStatus.Get Error: wrong kind/owner", owner)
-		return "", err
-	}
-
-	// service, err := ClientSet.CoreV1().Services(context.Namespace).Get(name, metav1.GetOptions{})
-	// if err != nil {
-	// 	log.Errorf("serviceStatus.Get Error: %+v\n", err.Error())
-	// 	if !strings.Contains(err.Error(), "not found") {
-	// 		return "", err
-	// 	}
-	// }
-	// Debugf(log, "The service: %+v\n", service)
-	var ports []string
-	for _, p := range service.Spec.Ports {
-		ports = append(ports, fmt.Sprintf("%s/%d", p.Name, p.Port))
+	dask.Status.SchedulerExternalAddress = ""
+	if address != "" {
+		scheme := "tcp"
+		if dcontext.TLS {
+			scheme = "tls"
+		}
+		dask.Status.SchedulerExternalAddress = fmt.Sprintf("%s://%s:%d", scheme, address, dcontext.Port)
 	}
-	portList := fmt.Sprintf("Service: %s Type: %s, IP: %s, Ports: %s", service.Name, service.Spec.Type, service.Spec.ClusterIP, strings.Join(ports[:], ","))
 
-	status, err := json.Marshal(&service.Status)
-	// status, err := json.Marshal(&service)
+	dask.Status.JupyterExternalURL = ""
+	if !dcontext.Jupyter {
+		return nil
+	}
+	address, err = r.serviceAddress(ctx, dask.Namespace, "jupyter-notebook-"+dask.Name)
 	if err != nil {
-		Errorf(log, err, "serviceStatus.json Error: %+v\n", err.Error())
-		return "", err
+		return err
+	}
+	if address != "" {
+		dask.Status.JupyterExternalURL = fmt.Sprintf("http://%s:8888%s", address, dcontext.NotebookBaseURL)
+		if dcontext.NotebookMode == "lab" {
+			dask.Status.JupyterExternalURL += "lab"
+		}
 	}
-	s := portList + " status: " + string(status)
-	Debugf(log, "The service status: %s\n", s)
-	return s, nil
+	return nil
 }
 
-// read back the status info for the Deployment resource
-func (r *DaskReconciler) deploymentStatus(dcontext dtypes.DaskContext, name string) (string, error) {
-
-	// pods, err := ClientSet.CoreV1().Pods("").List(metav1.ListOptions{})
-	// if err != nil {
-	// 	panic(err.Error())
-	// }
-	// fmt.Printf("There are %d pods in the cluster\n", len(pods.Items))
-	ctx := context.Background()
+// workerSelector selects the Pods of the worker Deployment or StatefulSet
+// that spec.replicas scales, leaving out any worker groups
+func workerSelector(name string) string {
+	return fmt.Sprintf("app.kubernetes.io/name=dask-worker,app.kubernetes.io/instance=%s,!%s", name, workerGroupLabel)
+}
 
-	objkey := client.ObjectKey{
-		Namespace: dcontext.Namespace,
-		Name:      name,
+// daskEndpoints fills in the addresses of the cluster services, preferring
+// the Ingress hosts where they are configured
+func daskEndpoints(dask *analyticsv1.Dask, dcontext dtypes.DaskContext) {
+	scheme := "tcp"
+	if dcontext.TLS {
+		scheme = "tls"
 	}
+	dask.Status.SchedulerAddress = fmt.Sprintf("%s://dask-scheduler-%s.%s:%d", scheme, dcontext.Name, dcontext.Namespace, dcontext.Port)
+	dask.Status.DashboardURL = dcontext.DashboardURL
+	if dask.Status.DashboardURL == "" {
+		dask.Status.DashboardURL = fmt.Sprintf("http://dask-scheduler-%s.%s:%d/", dcontext.Name, dcontext.Namespace, dcontext.BokehPort)
+	}
+	dask.Status.JupyterURL = ""
+	if dcontext.Jupyter {
+		if dcontext.JupyterIngress != "" {
+			dask.Status.JupyterURL = fmt.Sprintf("%s://%s%s", dcontext.IngressScheme(dcontext.JupyterIngress), dcontext.JupyterIngress, dcontext.NotebookBaseURL)
+		} else {
+			dask.Status.JupyterURL = fmt.Sprintf("http://jupyter-notebook-%s.%s:8888%s", dcontext.Name, dcontext.Namespace, dcontext.NotebookBaseURL)
+		}
+		if dcontext.NotebookMode == "lab" {
+			dask.Status.JupyterURL += "lab"
+		}
+	}
+}
 
-	log := r.Log.WithValues("deployment", objkey)
+// keepNodePorts carries over the node ports that Kubernetes allocated to a
+// Service, so that they do not change when the Service is updated
+func (r *DaskReconciler) keepNodePorts(ctx context.Context, service *corev1.Service) error {
+	if service.Spec.Type == corev1.ServiceTypeClusterIP || service.Spec.Type == "" {
+		return nil
+	}
+	current := &corev1.Service{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: service.Namespace, Name: service.Name}, current); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	allocated := map[string]int32{}
+	for _, port := range current.Spec.Ports {
+		allocated[port.Name] = port.NodePort
+	}
+	for i := range service.Spec.Ports {
+		if port := &service.Spec.Ports[i]; port.NodePort == 0 {
+			port.NodePort = allocated[port.Name]
+		}
+	}
+	return nil
+}
 
-	deployment := appsv1.Deployment{}
-	if err := r.Get(ctx, objkey, &deployment); err != nil {
-		Errorf(log, err, "deploymentStatus.Get Error: %+v\n", err.Error())
+// serviceAddress gives the address that a LoadBalancer Service has been
+// given - empty until then
+func (r *DaskReconciler) serviceAddress(ctx context.Context, namespace string, name string) (string, error) {
+	service := &corev1.Service{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, service); err != nil {
 		return "", client.IgnoreNotFound(err)
 	}
-	owner := metav1.GetControllerOf(&deployment)
-	if owner == nil {
-		err := errors.New("deploymentStatus.Get Error: owner empty")
-		log.Error(err, "deploymentStatus.Get Error: owner empty", owner)
-		return "", err
-	}
-	// ...make sure it's a Dask...
-	if owner.APIVersion != daskApiGVStr || owner.Kind != "Dask" {
-		err := errors.New("deploymentStatus.Get Error: wrong kind/owner")
-		log.Error(err, "deploymentStatus.Get Error: wrong kind/owner", owner)
-		return "", err
-	}
-
-	// deployment, err := ClientSet.AppsV1().Deployments(context.Namespace).Get(name, metav1.GetOptions{})
-	// if err != nil {
-	// 	log.Errorf("deploymentStatus.Get Error: %+v\n", err.Error())
-	// 	if !strings.Contains(err.Error(), "not found") {
-	// 		return "", err
-	// 	}
-	// }
-	Debugf(log, "The deployment: %+v\n", deployment)
-	status, err := json.Marshal(&deployment.Status)
-	if err != nil {
-		Errorf(log, err, "deploymentStatus.json Error: %+v\n", err.Error())
-		return "", err
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return "", nil
 	}
-	Debugf(log, "The deployment status: %s\n", string(status))
-	return string(status), nil
+	for _, i := range service.Status.LoadBalancer.Ingress {
+		if i.IP != "" {
+			return i.IP, nil
+		}
+		if i.Hostname != "" {
+			return i.Hostname, nil
+		}
+	}
+	return "", nil
 }
 
-// pull together the resource details
-func (r *DaskReconciler) resourceDetails(dcontext dtypes.DaskContext) (string, error) {
+// newIngress gives an empty Ingress of the API version in use
+func (r *DaskReconciler) newIngress() client.Object {
+	if r.ingressV1beta1 {
+		return &networkingv1beta1.Ingress{}
+	}
+	return &networkingv1.Ingress{}
+}
 
-	log := r.Log.WithName("resourceDetails")
+// daskIngress renders the Ingress in the API version in use
+func (r *DaskReconciler) daskIngress(dcontext dtypes.DaskContext) (client.Object, error) {
+	if r.ingressV1beta1 {
+		return models.DaskIngressV1beta1(dcontext)
+	}
+	return models.DaskIngress(dcontext)
+}
 
-	resIngress, err := r.ingressStatus(dcontext)
-	if err != nil {
-		Errorf(log, err, "ingressStatus Error: %s", err.Error())
-		return fmt.Sprintf("ingressStatus Error: %+v\n", err), err
+// newPodDisruptionBudget gives an empty PodDisruptionBudget of the API
+// version in use
+func (r *DaskReconciler) newPodDisruptionBudget() client.Object {
+	if r.pdbV1beta1 {
+		return &policyv1beta1.PodDisruptionBudget{}
 	}
-	resS, err := r.deploymentStatus(dcontext, "dask-scheduler-"+dcontext.Name)
-	resW, err := r.deploymentStatus(dcontext, "dask-worker-"+dcontext.Name)
-	resN, err := r.deploymentStatus(dcontext, "jupyter-notebook-"+dcontext.Name)
-	resSchedulerService, err := r.serviceStatus(dcontext, "dask-scheduler-"+dcontext.Name)
-	if err != nil {
-		Errorf(log, err, "serviceStatus scheduler Error: %+v\n", err)
-		return fmt.Sprintf("serviceStatus scheduler Error: %+v\n", err), err
+	return &policyv1.PodDisruptionBudget{}
+}
+
+// daskPodDisruptionBudget renders the PodDisruptionBudget of the Scheduler
+// or of the workers in the API version in use
+func (r *DaskReconciler) daskPodDisruptionBudget(dcontext dtypes.DaskContext, scheduler bool) (client.Object, error) {
+	switch {
+	case scheduler && r.pdbV1beta1:
+		return models.DaskSchedulerPodDisruptionBudgetV1beta1(dcontext)
+	case scheduler:
+		return models.DaskSchedulerPodDisruptionBudget(dcontext)
+	case r.pdbV1beta1:
+		return models.DaskWorkerPodDisruptionBudgetV1beta1(dcontext)
+	}
+	return models.DaskWorkerPodDisruptionBudget(dcontext)
+}
+
+// ingressCondition works out whether the Ingress has been given an address
+func (r *DaskReconciler) ingressCondition(ctx context.Context, dcontext dtypes.DaskContext) (bool, string, string, error) {
+	name := "dask-" + dcontext.Name
+	ingress := r.newIngress()
+	if err := r.Get(ctx, client.ObjectKey{Namespace: dcontext.Namespace, Name: name}, ingress); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return false, "", "", err
+		}
+		return false, "NotFound", fmt.Sprintf("Ingress %s not found", name), nil
+	}
+	var loadBalancer corev1.LoadBalancerStatus
+	switch i := ingress.(type) {
+	case *networkingv1.Ingress:
+		loadBalancer = i.Status.LoadBalancer
+	case *networkingv1beta1.Ingress:
+		loadBalancer = i.Status.LoadBalancer
+	}
+	var addresses []string
+	for _, i := range loadBalancer.Ingress {
+		if i.IP != "" {
+			addresses = append(addresses, i.IP)
+		}
+		if i.Hostname != "" {
+			addresses = append(addresses, i.Hostname)
+		}
 	}
-	var resJupyterService = ""
-	if dcontext.Jupyter {
-		resJupyterService, err = r.serviceStatus(dcontext, "jupyter-notebook-"+dcontext.Name)
-		if err != nil {
-			Errorf(log, err, "serviceStatus notebook Error: %+v\n", err)
-			return fmt.Sprintf("serviceStatus notebook Error: %+v\n", err), err
+	if len(addresses) == 0 {
+		return false, "Pending", fmt.Sprintf("Ingress %s is waiting for an address", name), nil
+	}
+	return true, "Ready", fmt.Sprintf("Ingress %s has address: %s", name, strings.Join(addresses, ", ")), nil
+}
+
+// writeSecret creates or replaces the data of a Secret owned by the Dask
+func (r *DaskReconciler) writeSecret(ctx context.Context, dask *analyticsv1.Dask, secret *corev1.Secret, exists bool) error {
+	if err := ctrl.SetControllerReference(dask, secret, r.Scheme); err != nil {
+		return err
+	}
+	if exists {
+		return r.Update(ctx, secret)
+	}
+	return r.Create(ctx, secret)
+}
+
+// getSecret looks up one of the Secrets generated for a Dask, reporting
+// whether it exists - a new one is labelled as the component
+func (r *DaskReconciler) getSecret(ctx context.Context, dask *analyticsv1.Dask, name string, component string) (*corev1.Secret, bool, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: dask.Namespace, Name: name}, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, false, err
 		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: dask.Namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/name":       component,
+					"app.kubernetes.io/instance":   dask.Name,
+					"app.kubernetes.io/managed-by": "DaskController",
+				},
+			},
+		}
+		return secret, false, nil
+	}
+	return secret, true, nil
+}
+
+// look up one of the worker statefulsets
+func (r *DaskReconciler) getStatefulSet(namespace string, name string, dask *analyticsv1.Dask) (*appsv1.StatefulSet, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("looking for statefulset", name)
+	statefulset := appsv1.StatefulSet{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &statefulset); err != nil {
+		Infof(log, "statefulset.Get Error: %+v\n", err.Error())
+		return nil, err
 	}
-	return fmt.Sprintf("%s - %s - %s\nScheduler: %s\nWorker: %s\nNotebook: %s", resIngress, resSchedulerService, resJupyterService, resS, resW, resN), nil
+	dask.Status.Components++
+	if statefulset.Status.ReadyReplicas == statefulset.Status.Replicas {
+		dask.Status.Succeeded++
+	}
+	return &statefulset, nil
 }
 
 // look up one of the deployments
@@ -258,49 +471,13 @@ func (r *DaskReconciler) getDeployment(namespace string, name string, dask *anal
 		Infof(log, "deployment.Get Error: %+v\n", err.Error())
 		return nil, err
 	}
-	dask.Status.Replicas++
+	dask.Status.Components++
 	if deployment.Status.ReadyReplicas == deployment.Status.Replicas {
 		dask.Status.Succeeded++
 	}
 	return &deployment, nil
 }
 
-// read back the status info for the Ingress resource
-func (r *DaskReconciler) getIngress(namespace string, name string) (*extv1beta1.Ingress, error) {
-	ctx := context.Background()
-	log := r.Log.WithValues("looking for ingress", name)
-	objkey := client.ObjectKey{
-		Namespace: namespace,
-		Name:      name,
-	}
-
-	ingress := extv1beta1.Ingress{}
-	if err := r.Get(ctx, objkey, &ingress); err != nil {
-		Errorf(log, err, "ingressStatus.Get Error: %+v\n", err.Error())
-		return nil, err
-	}
-
-	return &ingress, nil
-}
-
-// read back the status info for the Config resource
-func (r *DaskReconciler) getConfig(namespace string, name string) (*corev1.ConfigMap, error) {
-	ctx := context.Background()
-	log := r.Log.WithValues("looking for config", name)
-	objkey := client.ObjectKey{
-		Namespace: namespace,
-		Name:      name,
-	}
-
-	config := corev1.ConfigMap{}
-	if err := r.Get(ctx, objkey, &config); err != nil {
-		Errorf(log, err, "configStatus.Get Error: %+v\n", err.Error())
-		return nil, err
-	}
-
-	return &config, nil
-}
-
 // look up one of the jobs
 func (r *DaskJobReconciler) getJob(namespace string, name string, daskjob *analyticsv1.DaskJob) (*batchv1.Job, error) {
 	ctx := context.Background()
@@ -355,61 +532,3 @@ func (r *DaskJobReconciler) getPVC(namespace string, name string) (*corev1.Persi
 
 	return &pvc, nil
 }
-
-// read back the status info for the Deployment resource
-func (r *DaskJobReconciler) jobStatus(dcontext dtypes.DaskContext, name string) (string, error) {
-
-	// pods, err := ClientSet.CoreV1().Pods("").List(metav1.ListOptions{})
-	// if err != nil {
-	// 	panic(err.Error())
-	// }
-	// fmt.Printf("There are %d pods in the cluster\n", len(pods.Items))
-	ctx := context.Background()
-
-	objkey := client.ObjectKey{
-		Namespace: dcontext.Namespace,
-		Name:      name,
-	}
-
-	log := r.Log.WithValues("job", objkey)
-
-	job := batchv1.Job{}
-	if err := r.Get(ctx, objkey, &job); err != nil {
-		Errorf(log, err, "jobStatus.Get Error: %+v\n", err.Error())
-		return "", client.IgnoreNotFound(err)
-	}
-	owner := metav1.GetControllerOf(&job)
-	if owner == nil {
-		err := errors.New("jobStatus.Get Error: owner empty")
-		log.Error(err, "jobStatus.Get Error: owner empty", owner)
-		return "", err
-	}
-	// ...make sure it's a Dask...
-	if owner.APIVersion != daskjobApiGVStr || owner.Kind != "DaskJob" {
-		err := errors.New("jobStatus.Get Error: wrong kind/owner")
-		log.Error(err, "jobStatus.Get Error: wrong kind/owner", owner)
-		return "", err
-	}
-
-	Debugf(log, "The job: %+v\n", job)
-	status, err := json.Marshal(&job.Status)
-	if err != nil {
-		Errorf(log, err, "jobStatus.json Error: %+v\n", err.Error())
-		return "", err
-	}
-	Debugf(log, "The job status: %s\n", string(status))
-	return string(status), nil
-}
-
-// pull together the resource details
-func (r *DaskJobReconciler) resourceDetails(dcontext dtypes.DaskContext) (string, error) {
-
-	log := r.Log.WithName("resourceDetails")
-
-	resJob, err := r.jobStatus(dcontext, "daskjob-job-"+dcontext.Name)
-	if err != nil {
-		Errorf(log, err, "jobStatus Error: %+v\n", err)
-		return fmt.Sprintf("jobStatus Error: %+v\n", err), err
-	}
-	return fmt.Sprintf("Job: %s ", resJob), nil
-}
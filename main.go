@@ -26,7 +26,6 @@ import (
 	dtypes "gitlab.com/piersharding/dask-operator/types"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	extv1beta1 "k8s.io/api/extensions/v1beta1"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -50,7 +49,6 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = corev1.AddToScheme(scheme)
 	_ = appsv1.AddToScheme(scheme)
-	_ = extv1beta1.AddToScheme(scheme)
 	_ = analyticsv1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 
@@ -131,7 +129,7 @@ func main() {
 	}
 
 	if enableWebhooks || os.Getenv("ENABLE_WEBHOOKS") == "true" {
-		if err = (&analyticsv1.Dask{}).SetupWebhookWithManager(mgr); err != nil {
+		if err = (&analyticsv1.Dask{}).SetupWebhookWithManager(mgr, dtypes.Image); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Dask")
 			os.Exit(1)
 		}
@@ -24,6 +24,10 @@ metadata:
     app.kubernetes.io/instance: "{{ .Name }}"
     app.kubernetes.io/managed-by: DaskController
 data:
+{{- range $key, $config := .DaskConfigFiles }}
+  {{ $key }}: |
+{{ toYaml $config | indent 4 }}
+{{- end }}
   start-jupyter-notebook.sh: |
     #!/usr/bin/env bash
 
@@ -32,83 +36,86 @@ data:
     #source activate dask-distributed
     [ -f "${HOME}/.bash_profile" ] && source "${HOME}/.bash_profile"
 
+    # TLS security settings, picked up by distributed's Security()
+    if [ -n "${DASK_TLS_DIR-}" ]
+    then
+      export DASK_DISTRIBUTED__COMM__DEFAULT_SCHEME=tls
+      export DASK_DISTRIBUTED__COMM__REQUIRE_ENCRYPTION=True
+      export DASK_DISTRIBUTED__COMM__TLS__CA_FILE="${DASK_TLS_DIR}/ca.crt"
+      export DASK_DISTRIBUTED__COMM__TLS__CLIENT__CERT="${DASK_TLS_DIR}/tls.crt"
+      export DASK_DISTRIBUTED__COMM__TLS__CLIENT__KEY="${DASK_TLS_DIR}/tls.key"
+    fi
+
     # launch the notebook - the IP address to listen on is passed in via env-var IP
     mkdir -p /app
-    chmod 0777 /app
+    if [ "$(id -u)" = "0" ]
+    then
+      chmod 0777 /app
+    fi
     IP=${IP:-0.0.0.0}
     NOTEBOOK_PORT=${NOTEBOOK_PORT:-8888}
-    export JUPYTER_PASSWORD=${JUPYTER_PASSWORD:-changeme}
-    jupyter notebook --allow-root --no-browser --ip=${IP} \
-                     --port=${NOTEBOOK_PORT} \
-                     --config=/jupyter_notebook_config.py /app
+    if [ -z "${JUPYTER_TOKEN-}" ] && [ -z "${JUPYTER_PASSWORD_HASH-}" ]
+    then
+      export JUPYTER_PASSWORD=${JUPYTER_PASSWORD:-changeme}
+    fi
+    export NOTEBOOK_BASE_URL=${NOTEBOOK_BASE_URL:-/}
+
+    if [ "${NOTEBOOK_MODE-}" = "lab" ]
+    then
+      # point the Dask JupyterLab extension at the cluster dashboard
+      if python -c "import dask_labextension" >/dev/null 2>&1
+      then
+        SETTINGS_DIR="$(python -c "from jupyterlab.commands import get_app_dir; print(get_app_dir())")/settings"
+        if mkdir -p "${SETTINGS_DIR}" 2>/dev/null && [ -w "${SETTINGS_DIR}" ]
+        then
+          echo "{\"dask-labextension:plugin\": {\"defaultURL\": \"${DASK_DASHBOARD_URL}\"}}" > "${SETTINGS_DIR}/overrides.json"
+        else
+          # the application directory is read-only - use the user settings instead
+          SETTINGS_DIR="${JUPYTERLAB_SETTINGS_DIR:-${HOME}/.jupyter/lab/user-settings}/dask-labextension"
+          mkdir -p "${SETTINGS_DIR}"
+          echo "{\"defaultURL\": \"${DASK_DASHBOARD_URL}\"}" > "${SETTINGS_DIR}/plugin.jupyterlab-settings"
+        fi
+        echo "Dask JupyterLab extension dashboard: ${DASK_DASHBOARD_URL}"
+      else
+        echo "dask-labextension is not installed - the Dask extension is not configured"
+      fi
+      jupyter lab --allow-root --no-browser --ip=${IP} \
+                  --port=${NOTEBOOK_PORT} \
+                  --config=/jupyter_notebook_config.py "$@" /app
+    else
+      jupyter notebook --allow-root --no-browser --ip=${IP} \
+                       --port=${NOTEBOOK_PORT} \
+                       --config=/jupyter_notebook_config.py "$@" /app
+    fi
 
   jupyter_notebook_config.py: |
     import errno
     import os
     import stat
     import subprocess
-    
+
     from jupyter_core.paths import jupyter_data_dir
-    from notebook.auth import passwd
-    
+    try:
+        from jupyter_server.auth import passwd
+    except ImportError:
+        from notebook.auth import passwd
+
     # Add global to quiet error checking but I suspect that this file is defunct
     global c
-    
+
+    # JupyterLab runs on jupyter_server, and the classic Notebook on NotebookApp
+    app = c.ServerApp if os.environ.get('NOTEBOOK_MODE') == 'lab' else c.NotebookApp
+
     # Setup the Notebook to listen on all interfaces on port 8888 by default
-    c.NotebookApp.ip = '*'
-    c.NotebookApp.port = 8888
-    c.NotebookApp.open_browser = False
-    
-    # Configure Networking while running under Marathon:
-    if 'MARATHON_APP_ID' in os.environ:
-        if 'PORT_JUPYTER' in os.environ:
-            c.NotebookApp.port = int(os.environ['PORT_JUPYTER'])
-    
-        # Set the Access-Control-Allow-Origin header
-        c.NotebookApp.allow_origin = '*'
-    
-        # Set Jupyter Notebook Server password to 'jupyter-<Marathon-App-Prefix>'
-        # e.g., Marathon App ID '/foo/bar/app' maps to password: 'jupyter-foo-bar'
-        MARATHON_APP_PREFIX = \
-            '-'.join(os.environ['MARATHON_APP_ID'].split('/')[:-1])
-        c.NotebookApp.password = passwd('jupyter{}'.format(MARATHON_APP_PREFIX))
-    
-        # Allow CORS and TLS from behind Marathon-LB/HAProxy
-        # Trust X-Scheme/X-Forwarded-Proto and X-Real-Ip/X-Forwarded-For
-        # Necessary if the proxy handles SSL
-        if 'MARATHON_APP_LABEL_HAPROXY_GROUP' in os.environ:
-            c.NotebookApp.trust_xheaders = True
-    
-        if 'MARATHON_APP_LABEL_HAPROXY_0_VHOST' in os.environ:
-            c.NotebookApp.allow_origin = \
-                'http://{}'.format(
-                    os.environ['MARATHON_APP_LABEL_HAPROXY_0_VHOST']
-                )
-    
-        if 'MARATHON_APP_LABEL_HAPROXY_0_REDIRECT_TO_HTTPS' in os.environ:
-            c.NotebookApp.allow_origin = \
-                'https://{}'.format(
-                    os.environ['MARATHON_APP_LABEL_HAPROXY_0_VHOST']
-                )
-    
-        # Set the Jupyter Notebook server base URL to the HAPROXY_PATH specified
-        if 'MARATHON_APP_LABEL_HAPROXY_0_PATH' in os.environ:
-            c.NotebookApp.base_url = \
-                os.environ['MARATHON_APP_LABEL_HAPROXY_0_PATH']
-    
-        # Setup TLS
-        if 'USE_HTTPS' in os.environ:
-            SCHEDULER_TLS_CERT = os.environ.get('TLS_CERT_PATH', '/'.join(
-                [os.environ['MESOS_SANDBOX'],
-                 '.ssl',
-                 'scheduler.crt']))
-            c.NotebookApp.certfile = SCHEDULER_TLS_CERT
-            SCHEDULER_TLS_KEY = os.environ.get('TLS_KEY_PATH', '/'.join(
-                [os.environ['MESOS_SANDBOX'],
-                 '.ssl',
-                 'scheduler.key']))
-            c.NotebookApp.keyfile = SCHEDULER_TLS_KEY
-    
+    app.ip = '*'
+    app.port = int(os.environ.get('NOTEBOOK_PORT', '8888'))
+    app.open_browser = False
+
+    # Serve under the base URL of a path-prefixed Ingress, trusting the
+    # X-Scheme/X-Forwarded-Proto and X-Real-Ip/X-Forwarded-For headers it sets
+    app.base_url = os.environ.get('NOTEBOOK_BASE_URL', '/')
+    app.trust_xheaders = True
+
     # Set a certificate if USE_HTTPS is set to any value
     PEM_FILE = os.path.join(jupyter_data_dir(), 'notebook.pem')
     if 'USE_HTTPS' in os.environ:
@@ -129,14 +136,30 @@ data:
                                    '-keyout', PEM_FILE, '-out', PEM_FILE])
             # Restrict access to PEM_FILE
             os.chmod(PEM_FILE, stat.S_IRUSR | stat.S_IWUSR)
-        c.NotebookApp.certfile = PEM_FILE
-    
+        app.certfile = PEM_FILE
+
+    # let jupyter-server-proxy through to the Dask dashboard of the cluster
+    if 'DASK_DASHBOARD_HOST' in os.environ:
+        c.ServerProxy.host_allowlist = ['localhost', os.environ['DASK_DASHBOARD_HOST']]
+        # before jupyter-server-proxy 3.2
+        c.ServerProxy.host_whitelist = c.ServerProxy.host_allowlist
+
     # Set a password if JUPYTER_PASSWORD is set
     if 'JUPYTER_PASSWORD' in os.environ:
-        c.NotebookApp.password = passwd(os.environ['JUPYTER_PASSWORD'])
+        app.password = passwd(os.environ['JUPYTER_PASSWORD'])
         del os.environ['JUPYTER_PASSWORD']
-    
-    
+
+    # Set an already hashed password if JUPYTER_PASSWORD_HASH is set
+    if 'JUPYTER_PASSWORD_HASH' in os.environ:
+        app.password = os.environ['JUPYTER_PASSWORD_HASH']
+        del os.environ['JUPYTER_PASSWORD_HASH']
+
+    # Set a token if JUPYTER_TOKEN is set
+    if 'JUPYTER_TOKEN' in os.environ:
+        app.token = os.environ['JUPYTER_TOKEN']
+        del os.environ['JUPYTER_TOKEN']
+
+
   start-dask-scheduler.sh: |
     #!/usr/bin/env bash
     ## force upgrade of dask because 2.3.0 is buggered!
@@ -152,24 +175,62 @@ data:
     echo "Complete environment:"
     printenv
 
+    # TLS security settings, picked up by distributed's Security()
+    if [ -n "${DASK_TLS_DIR-}" ]
+    then
+      export DASK_DISTRIBUTED__COMM__DEFAULT_SCHEME=tls
+      export DASK_DISTRIBUTED__COMM__REQUIRE_ENCRYPTION=True
+      export DASK_DISTRIBUTED__COMM__TLS__CA_FILE="${DASK_TLS_DIR}/ca.crt"
+      export DASK_DISTRIBUTED__COMM__TLS__SCHEDULER__CERT="${DASK_TLS_DIR}/tls.crt"
+      export DASK_DISTRIBUTED__COMM__TLS__SCHEDULER__KEY="${DASK_TLS_DIR}/tls.key"
+    fi
+{{ template "daskLine" . }}
+
     if [ \( -n "${KUBERNETES_SERVICE_HOST-}" \) ]
     then
 
+      TLS_ARGS=()
+      if [ -n "${DASK_TLS_DIR-}" ]
+      then
+        TLS_ARGS=(--tls-ca-file "${DASK_TLS_DIR}/ca.crt" --tls-cert "${DASK_TLS_DIR}/tls.crt" --tls-key "${DASK_TLS_DIR}/tls.key")
+      fi
+
+      # enable the HTTP API used to retire workers gracefully, where this
+      # version of distributed has it
+      if python -c "import distributed.http.scheduler.api" >/dev/null 2>&1
+      then
+        export DASK_DISTRIBUTED__SCHEDULER__HTTP__ROUTES="$(python -c \
+          "import dask, distributed; routes = dask.config.get('distributed.scheduler.http.routes'); print(routes + ['distributed.http.scheduler.api'] if 'distributed.http.scheduler.api' not in routes else routes)" \
+        )"
+        echo "Scheduler HTTP routes: ${DASK_DISTRIBUTED__SCHEDULER__HTTP__ROUTES}"
+      fi
+
+      # the Schedulers before the dask CLI take the local directory, and need
+      # to be told to trust the X-Forwarded headers of the Ingress
+      SCHEDULER_ARGS=()
+      if [ "${DASK_SCHEDULER_LEGACY}" = "true" ]
+      then
+        SCHEDULER_ARGS=(--use-xheaders "True" --local-directory "${DASK_LOCAL_DIRECTORY}")
+      else
+        export DASK_TEMPORARY_DIRECTORY="${DASK_LOCAL_DIRECTORY}"
+      fi
+
       echo ""
       echo "Command to run: "
-      echo dask-scheduler --host "${DASK_HOST_NAME}" --port "${DASK_PORT_SCHEDULER}" --dashboard-address "${DASK_PORT_BOKEH}" --dashboard --dashboard-prefix "${DASK_BOKEH_APP_PREFIX}" --use-xheaders "True" --scheduler-file "dask-scheduler-connection" --local-directory "${DASK_LOCAL_DIRECTORY}"
+      echo ${DASK_SCHEDULER_COMMAND} --host "${DASK_HOST_NAME}" --port "${DASK_PORT_SCHEDULER}" --dashboard-address "${DASK_PORT_BOKEH}" --dashboard --dashboard-prefix "${DASK_BOKEH_APP_PREFIX}" --scheduler-file "${DASK_LOCAL_DIRECTORY}/dask-scheduler-connection" "${SCHEDULER_ARGS[@]}" "${TLS_ARGS[@]}" "$@"
 
-      dask-scheduler \
+      ${DASK_SCHEDULER_COMMAND} \
         --host "${DASK_HOST_NAME}" \
         --port "${DASK_PORT_SCHEDULER}" \
         --dashboard-address "${DASK_PORT_BOKEH}" \
         --dashboard \
         --dashboard-prefix "${DASK_BOKEH_APP_PREFIX}" \
-        --use-xheaders "True" \
-        --scheduler-file "dask-scheduler-connection" \
-        --local-directory "${DASK_LOCAL_DIRECTORY}"
+        --scheduler-file "${DASK_LOCAL_DIRECTORY}/dask-scheduler-connection" \
+        "${SCHEDULER_ARGS[@]}" \
+        "${TLS_ARGS[@]}" \
+        "$@"
     else
-      dask-scheduler "$@"
+      ${DASK_SCHEDULER_COMMAND} "$@"
     fi
 
   start-dask-worker.sh: |
@@ -186,33 +247,78 @@ data:
     
     echo "Complete environment:"
     printenv
-    
+
+    # TLS security settings, picked up by distributed's Security()
+    if [ -n "${DASK_TLS_DIR-}" ]
+    then
+      export DASK_DISTRIBUTED__COMM__DEFAULT_SCHEME=tls
+      export DASK_DISTRIBUTED__COMM__REQUIRE_ENCRYPTION=True
+      export DASK_DISTRIBUTED__COMM__TLS__CA_FILE="${DASK_TLS_DIR}/ca.crt"
+      export DASK_DISTRIBUTED__COMM__TLS__WORKER__CERT="${DASK_TLS_DIR}/tls.crt"
+      export DASK_DISTRIBUTED__COMM__TLS__WORKER__KEY="${DASK_TLS_DIR}/tls.key"
+    fi
+{{ template "daskLine" . }}
+
     if [ \( -n "${KUBERNETES_SERVICE_HOST-}" \) ]
     then
         echo "Dask Scheduler: ${DASK_SCHEDULER}"
-        NBYTES=$(python -c \
-            "import os; print(''.join([str(int(float(os.environ['DASK_MEM_LIMIT']) * 0.8)), 'e6']))" \
-        )
-        echo "Dask Worker Memory Limit in Bytes (1 Megabyte=1e6, 1 Gigabyte=1e9): ${NBYTES}"
-
-        NTHREADS=$(python -c \
-            "import os,math; print(int(math.ceil(float(os.environ['DASK_CPU_LIMIT']))))" \
-        )
-        echo "Dask Worker Threads: ${NTHREADS}"
+        TLS_ARGS=()
+        if [ -n "${DASK_TLS_DIR-}" ]
+        then
+          TLS_ARGS=(--tls-ca-file "${DASK_TLS_DIR}/ca.crt" --tls-cert "${DASK_TLS_DIR}/tls.crt" --tls-key "${DASK_TLS_DIR}/tls.key")
+        fi
+        # the worker processes share out the CPU and memory of the container,
+        # taken from its limits or else its requests - without either,
+        # dask-worker works them out for itself
+        NWORKERS="${DASK_NWORKERS:-1}"
+        WORKER_ARGS=()
+        NTHREADS="${DASK_NTHREADS-}"
+        if [ -z "${NTHREADS}" ] && [ -n "${DASK_CPU_MILLICORES-}" ]
+        then
+          NTHREADS=$(( (DASK_CPU_MILLICORES + 1000 * NWORKERS - 1) / (1000 * NWORKERS) ))
+          if [ "${NTHREADS}" -lt 1 ]
+          then
+            NTHREADS=1
+          fi
+        fi
+        if [ -n "${NTHREADS}" ]
+        then
+          WORKER_ARGS+=(--nthreads "${NTHREADS}")
+        fi
+        echo "Dask Worker Threads: ${NTHREADS:-auto}"
+
+        MEMORY_LIMIT=auto
+        if [ -n "${DASK_MEMORY_BYTES-}" ]
+        then
+          MEMORY_LIMIT=$(python -c \
+              "import os; print(int(int(os.environ['DASK_MEMORY_BYTES']) * float(os.environ.get('DASK_MEMORY_FRACTION', '0.8')) / int(os.environ.get('DASK_NWORKERS', '1'))))" \
+          )
+        fi
+        WORKER_ARGS+=(--memory-limit "${MEMORY_LIMIT}")
+        echo "Dask Worker Memory Limit in Bytes per process: ${MEMORY_LIMIT}"
+
+        WORKER_ARGS+=("${DASK_PROCESSES_FLAG}" "${NWORKERS}")
+        # the ports are only fixed for a single process
+        if [ "${NWORKERS}" = "1" ]
+        then
+          WORKER_ARGS+=(--worker-port "${DASK_PORT_WORKER}" --nanny-port "${DASK_PORT_NANNY}")
+        fi
+        if [ -n "${DASK_RESOURCES-}" ]
+        then
+          WORKER_ARGS+=(--resources "${DASK_RESOURCES}")
+        fi
+
         # dask-worker --memory-limit 7516192768 --local-directory /arl/tmp --host ${IP} --bokeh --bokeh-port 8788  --nprocs 2 --nthreads 2 --reconnect "${DASK_SCHEDULER}"
-        dask-worker \
+        ${DASK_WORKER_COMMAND} \
             --host "${DASK_HOST_NAME}" \
-            --worker-port "${DASK_PORT_WORKER}" \
-            --nanny-port "${DASK_PORT_NANNY}" \
             --dashboard \
             --dashboard-address "${DASK_PORT_BOKEH}" \
-            --nthreads "${NTHREADS}" \
-            --nprocs "1" \
-            --name "${DASK_UID}" \
-            --memory-limit "${NBYTES}" \
+            --name "${DASK_WORKER_NAME:-${DASK_UID}}" \
             --local-directory "${DASK_LOCAL_DIRECTORY}" \
-            --resources "${DASK_RESOURCES}" \
             --death-timeout "180" \
+            "${WORKER_ARGS[@]}" \
+            "${TLS_ARGS[@]}" \
+            "$@" \
             "${DASK_SCHEDULER}:${DASK_PORT_SCHEDULER}"
         #dask-worker \
         #    --local-directory "${DASK_LOCAL_DIRECTORY}" \
@@ -220,12 +326,45 @@ data:
         #    --dashboard-address "${DASK_PORT_BOKEH}" \
         #"${DASK_SCHEDULER}:${DASK_PORT_SCHEDULER}"            
     else
-        dask-worker "$@"
+        ${DASK_WORKER_COMMAND} "$@"
         echo "Dask Scheduler: ${DASK_SCHEDULER}"
         # dask-worker --memory-limit 7516192768 --local-directory /arl/tmp --host ${IP} --bokeh --bokeh-port 8788  --nprocs 2 --nthreads 2 --reconnect "${DASK_SCHEDULER}"
     fi
     
+{{- define "daskLine" }}
+    # the command line of the Dask release line - that of the daskVersion,
+    # or else of the version of distributed in the image
+{{- if .DaskVersion }}
+    DASK_VERSION="{{ .DaskVersion }}"
+{{- else }}
+    DASK_VERSION="$(python -c 'import distributed; print(distributed.__version__)' 2>/dev/null || true)"
+{{- end }}
+    echo "Dask version: ${DASK_VERSION:-unknown}"
+{{- range $i, $line := .DaskLines }}
+    {{ if $i }}elif{{ else }}if{{ end }} [ "$(printf '%s\n' "{{ $line.Since }}" "${DASK_VERSION:-0}" | sort -V | head -n 1)" = "{{ $line.Since }}" ]
+    then
+      DASK_SCHEDULER_COMMAND="{{ $line.Scheduler }}"
+      DASK_WORKER_COMMAND="{{ $line.Worker }}"
+      DASK_PROCESSES_FLAG="{{ $line.Processes }}"
+      DASK_SCHEDULER_LEGACY="{{ $line.Legacy }}"
+{{- end }}
+    fi
+{{- end }}
 `
+	// the dask.yaml of the cluster, and of each component with its own
+	files := map[string]interface{}{}
+	components := []dtypes.DaskContext{dcontext, dcontext.ForScheduler(), dcontext.ForWorker(), dcontext.ForNotebook()}
+	for _, group := range dcontext.WorkerGroups {
+		components = append(components, dcontext.ForWorkerGroup(group))
+	}
+	for _, component := range components {
+		if len(component.DaskConfig) > 0 {
+			files[component.DaskConfigKey] = component.DaskConfig
+		}
+	}
+	dcontext.DaskConfigFiles = files
+	dcontext.DaskLines = daskLinesFor(dcontext.DaskVersion)
+
 	result, err := utils.ApplyTemplate(daskConfigs, dcontext)
 	if err != nil {
 		log.Debugf("ApplyTemplate Error: %+v\n", err)
@@ -253,6 +392,10 @@ metadata:
     app.kubernetes.io/instance: "{{ .Name }}"
     app.kubernetes.io/managed-by: DaskJobController
 data:
+{{- with .DaskConfig }}
+  dask.yaml: |
+{{ toYaml . | indent 4 }}
+{{- end }}
     
   app.{{ .ScriptType }}: |
 {{ .ScriptContents | indent 4 }}
@@ -359,6 +502,16 @@ data:
     
     [ -f "${HOME}/.bash_profile" ] && source "${HOME}/.bash_profile"
 
+    # TLS security settings, picked up by distributed's Security()
+    if [ -n "${DASK_TLS_DIR-}" ]
+    then
+      export DASK_DISTRIBUTED__COMM__DEFAULT_SCHEME=tls
+      export DASK_DISTRIBUTED__COMM__REQUIRE_ENCRYPTION=True
+      export DASK_DISTRIBUTED__COMM__TLS__CA_FILE="${DASK_TLS_DIR}/ca.crt"
+      export DASK_DISTRIBUTED__COMM__TLS__CLIENT__CERT="${DASK_TLS_DIR}/tls.crt"
+      export DASK_DISTRIBUTED__COMM__TLS__CLIENT__KEY="${DASK_TLS_DIR}/tls.key"
+    fi
+
     export SCRIPT_TYPE="{{ .ScriptType }}"
     export MOUNTED_FILE="{{ .MountedFile }}"
     export REPORTS_DIR=${REPORTS_DIR:-/reports}
@@ -384,7 +537,13 @@ data:
         echo "Launching /app.ipynb"
         export JUPYTER_PASSWORD=${JUPYTER_PASSWORD:-changeme}
         TIMEOUT=${TIMEOUT:-3600}
-        [ -d "${REPORTS_DIR}" ] || ( mkdir -p ${REPORTS_DIR} && chmod 777 ${REPORTS_DIR} )
+        mkdir -p "${REPORTS_DIR}"
+        if [ "$(id -u)" = "0" ]
+        then
+          # the report volume belongs to the fsGroup of the Pod, so the
+          # group is all that needs to write to it
+          chmod 0770 "${REPORTS_DIR}"
+        fi
         jupyter nbconvert --execute \
                           --ExecutePreprocessor.timeout=${TIMEOUT} \
                           --config=/jupyter_notebook_config.py \
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/appscode/go/log"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	"gitlab.com/piersharding/dask-operator/utils"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+)
+
+const schedulerPodDisruptionBudget = `
+apiVersion: policy/{{ if .PDBV1 }}v1{{ else }}v1beta1{{ end }}
+kind: PodDisruptionBudget
+metadata:
+  name: dask-scheduler-{{ .Name }}
+  namespace: {{ .Namespace }}
+  labels:
+    app.kubernetes.io/name: dask-scheduler
+    app.kubernetes.io/instance: "{{ .Name }}"
+    app.kubernetes.io/managed-by: DaskController
+spec:
+  minAvailable: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: dask-scheduler
+      app.kubernetes.io/instance: "{{ .Name }}"
+`
+
+const workerPodDisruptionBudget = `
+apiVersion: policy/{{ if .PDBV1 }}v1{{ else }}v1beta1{{ end }}
+kind: PodDisruptionBudget
+metadata:
+  name: dask-worker-{{ .Name }}
+  namespace: {{ .Namespace }}
+  labels:
+    app.kubernetes.io/name: dask-worker
+    app.kubernetes.io/instance: "{{ .Name }}"
+    app.kubernetes.io/managed-by: DaskController
+spec:
+{{- with .MinAvailable }}
+  minAvailable: {{ toJson . }}
+{{- end }}
+{{- with .MaxUnavailable }}
+  maxUnavailable: {{ toJson . }}
+{{- end }}
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: dask-worker
+      app.kubernetes.io/instance: "{{ .Name }}"
+`
+
+// podDisruptionBudget renders a policy/v1 PodDisruptionBudget
+func podDisruptionBudget(template string, dcontext dtypes.DaskContext) (*policyv1.PodDisruptionBudget, error) {
+	dcontext.PDBV1 = true
+	result, err := utils.ApplyTemplate(template, dcontext)
+	if err != nil {
+		log.Debugf("ApplyTemplate Error: %+v\n", err)
+		return nil, err
+	}
+	pdb := &policyv1.PodDisruptionBudget{}
+	if err := json.Unmarshal([]byte(result), pdb); err != nil {
+		return nil, err
+	}
+	return pdb, err
+}
+
+// podDisruptionBudgetV1beta1 renders a policy/v1beta1 PodDisruptionBudget,
+// for clusters older than 1.21
+func podDisruptionBudgetV1beta1(template string, dcontext dtypes.DaskContext) (*policyv1beta1.PodDisruptionBudget, error) {
+	dcontext.PDBV1 = false
+	result, err := utils.ApplyTemplate(template, dcontext)
+	if err != nil {
+		log.Debugf("ApplyTemplate Error: %+v\n", err)
+		return nil, err
+	}
+	pdb := &policyv1beta1.PodDisruptionBudget{}
+	if err := json.Unmarshal([]byte(result), pdb); err != nil {
+		return nil, err
+	}
+	return pdb, err
+}
+
+// DaskSchedulerPodDisruptionBudget generates the policy/v1
+// PodDisruptionBudget description for the Dask Scheduler
+func DaskSchedulerPodDisruptionBudget(dcontext dtypes.DaskContext) (*policyv1.PodDisruptionBudget, error) {
+	return podDisruptionBudget(schedulerPodDisruptionBudget, dcontext)
+}
+
+// DaskSchedulerPodDisruptionBudgetV1beta1 generates the policy/v1beta1
+// PodDisruptionBudget description for the Dask Scheduler
+func DaskSchedulerPodDisruptionBudgetV1beta1(dcontext dtypes.DaskContext) (*policyv1beta1.PodDisruptionBudget, error) {
+	return podDisruptionBudgetV1beta1(schedulerPodDisruptionBudget, dcontext)
+}
+
+// DaskWorkerPodDisruptionBudget generates the policy/v1 PodDisruptionBudget
+// description for the Dask workers, across all of the worker groups
+func DaskWorkerPodDisruptionBudget(dcontext dtypes.DaskContext) (*policyv1.PodDisruptionBudget, error) {
+	return podDisruptionBudget(workerPodDisruptionBudget, dcontext)
+}
+
+// DaskWorkerPodDisruptionBudgetV1beta1 generates the policy/v1beta1
+// PodDisruptionBudget description for the Dask workers
+func DaskWorkerPodDisruptionBudgetV1beta1(dcontext dtypes.DaskContext) (*policyv1beta1.PodDisruptionBudget, error) {
+	return podDisruptionBudgetV1beta1(workerPodDisruptionBudget, dcontext)
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/appscode/go/log"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	"gitlab.com/piersharding/dask-operator/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// httpRoute renders a Gateway API HTTPRoute - there are no Go types for the
+// Gateway API here, so the result is unstructured
+func httpRoute(template string, dcontext dtypes.DaskContext) (*unstructured.Unstructured, error) {
+	result, err := utils.ApplyTemplate(template, dcontext)
+	if err != nil {
+		log.Debugf("ApplyTemplate Error: %+v\n", err)
+		return nil, err
+	}
+	route := &unstructured.Unstructured{}
+	if err := json.Unmarshal([]byte(result), &route.Object); err != nil {
+		return nil, err
+	}
+	return route, err
+}
+
+// JupyterHTTPRoute generates the HTTPRoute description for
+// the Jupyter Notebook
+func JupyterHTTPRoute(dcontext dtypes.DaskContext) (*unstructured.Unstructured, error) {
+
+	const jupyterHTTPRoute = `
+apiVersion: gateway.networking.k8s.io/{{ .GatewayAPIVersion }}
+kind: HTTPRoute
+metadata:
+  name: jupyter-notebook-{{ .Name }}
+  namespace: {{ .Namespace }}
+  labels:
+    app.kubernetes.io/name: jupyter-notebook
+    app.kubernetes.io/instance: "{{ .Name }}"
+    app.kubernetes.io/managed-by: DaskController
+spec:
+  parentRefs:
+  - name: {{ .GatewayName }}
+    namespace: {{ .GatewayNamespace }}
+{{- with .GatewaySection }}
+    sectionName: {{ . }}
+{{- end }}
+{{- with .JupyterIngress }}
+  hostnames:
+  - {{ . }}
+{{- end }}
+  rules:
+  - matches:
+    - path:
+        type: PathPrefix
+        value: {{ .NotebookBaseURL }}
+    backendRefs:
+    - name: jupyter-notebook-{{ .Name }}
+      port: 8888
+`
+	return httpRoute(jupyterHTTPRoute, dcontext)
+}
+
+// DashboardHTTPRoute generates the HTTPRoute description for
+// the Dask Scheduler dashboard
+func DashboardHTTPRoute(dcontext dtypes.DaskContext) (*unstructured.Unstructured, error) {
+
+	const dashboardHTTPRoute = `
+apiVersion: gateway.networking.k8s.io/{{ .GatewayAPIVersion }}
+kind: HTTPRoute
+metadata:
+  name: dask-dashboard-{{ .Name }}
+  namespace: {{ .Namespace }}
+  labels:
+    app.kubernetes.io/name: dask-dashboard
+    app.kubernetes.io/instance: "{{ .Name }}"
+    app.kubernetes.io/managed-by: DaskController
+spec:
+  parentRefs:
+  - name: {{ .GatewayName }}
+    namespace: {{ .GatewayNamespace }}
+{{- with .GatewaySection }}
+    sectionName: {{ . }}
+{{- end }}
+{{- with .DashboardHostname }}
+  hostnames:
+  - {{ . }}
+{{- end }}
+  rules:
+  - matches:
+    - path:
+        type: PathPrefix
+        value: /
+    backendRefs:
+    - name: dask-scheduler-{{ .Name }}
+      port: {{ .BokehPort }}
+`
+	return httpRoute(dashboardHTTPRoute, dcontext)
+}
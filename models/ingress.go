@@ -6,15 +6,28 @@ import (
 	"github.com/appscode/go/log"
 	dtypes "gitlab.com/piersharding/dask-operator/types"
 	"gitlab.com/piersharding/dask-operator/utils"
-	v1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 )
 
-// DaskIngress generates the Ingress description for
-// the Dask cluster
-func DaskIngress(dcontext dtypes.DaskContext) (*v1beta1.Ingress, error) {
+// daskIngress renders the Ingress for the Dask cluster, in the form of
+// networking.k8s.io/v1, or v1beta1 for clusters that do not have it
+func daskIngress(dcontext dtypes.DaskContext) (string, error) {
 
 	const daskIngress = `
-apiVersion: extensions/v1beta1
+{{- define "backend" }}
+        backend:
+{{- if .v1 }}
+          service:
+            name: {{ .service }}
+            port:
+              number: {{ .port }}
+{{- else }}
+          serviceName: {{ .service }}
+          servicePort: {{ .port }}
+{{- end }}
+{{- end -}}
+apiVersion: networking.k8s.io/{{ if .IngressV1 }}v1{{ else }}v1beta1{{ end }}
 kind: Ingress
 metadata:
   name: dask-{{ .Name }}
@@ -23,44 +36,73 @@ metadata:
     app.kubernetes.io/name: dask
     app.kubernetes.io/instance: "{{ .Name }}"
     app.kubernetes.io/managed-by: DaskController
+{{- with .IngressAnnotations }}
   annotations:
-    kubernetes.io/ingress.class: nginx
-    nginx.ingress.kubernetes.io/x-forwarded-prefix: "true"
-    nginx.ingress.kubernetes.io/ssl-redirect: "false"
+{{ toYaml . | indent 4 }}
+{{- end }}
 spec:
+{{- with .IngressClassName }}
+  ingressClassName: {{ . }}
+{{- end }}
+{{- with .IngressTLS }}
+  tls:
+{{ toYaml . | indent 2 }}
+{{- end }}
   rules:
 {{- if and .Jupyter .JupyterIngress}}
   - host: {{ .JupyterIngress }}
     http:
       paths:
-      - path: /
-        backend:
-          serviceName:  jupyter-notebook-{{ .Name }}
-          servicePort: 8888
+      - path: {{ .NotebookBaseURL }}
+        pathType: Prefix
+{{- template "backend" (dict "v1" .IngressV1 "service" (print "jupyter-notebook-" .Name) "port" 8888) }}
 {{- end }}
 {{- if .SchedulerIngress}}
   - host: {{ .SchedulerIngress }}
     http:
       paths:
       - path: /
-        backend:
-          serviceName:  dask-scheduler-{{ .Name }}
-          servicePort: {{.Port }}
+        pathType: Prefix
+{{- template "backend" (dict "v1" .IngressV1 "service" (print "dask-scheduler-" .Name) "port" .Port) }}
   - host: {{ .MonitorIngress }}
     http:
       paths:
       - path: /
-        backend:
-          serviceName:  dask-scheduler-{{ .Name }}
-          servicePort: {{ .BokehPort }}
+        pathType: Prefix
+{{- template "backend" (dict "v1" .IngressV1 "service" (print "dask-scheduler-" .Name) "port" .BokehPort) }}
 {{- end }}
 `
 	result, err := utils.ApplyTemplate(daskIngress, dcontext)
 	if err != nil {
 		log.Debugf("ApplyTemplate Error: %+v\n", err)
+	}
+	return result, err
+}
+
+// DaskIngress generates the networking.k8s.io/v1 Ingress description for
+// the Dask cluster
+func DaskIngress(dcontext dtypes.DaskContext) (*networkingv1.Ingress, error) {
+	dcontext.IngressV1 = true
+	result, err := daskIngress(dcontext)
+	if err != nil {
+		return nil, err
+	}
+	ingress := &networkingv1.Ingress{}
+	if err := json.Unmarshal([]byte(result), ingress); err != nil {
+		return nil, err
+	}
+	return ingress, err
+}
+
+// DaskIngressV1beta1 generates the networking.k8s.io/v1beta1 Ingress
+// description for the Dask cluster, for clusters older than 1.19
+func DaskIngressV1beta1(dcontext dtypes.DaskContext) (*networkingv1beta1.Ingress, error) {
+	dcontext.IngressV1 = false
+	result, err := daskIngress(dcontext)
+	if err != nil {
 		return nil, err
 	}
-	ingress := &v1beta1.Ingress{}
+	ingress := &networkingv1beta1.Ingress{}
 	if err := json.Unmarshal([]byte(result), ingress); err != nil {
 		return nil, err
 	}
@@ -73,6 +73,13 @@ spec:
     spec:
       serviceAccountName: "daskjob-serviceaccount-{{ .Name }}"
       restartPolicy: Never
+{{- with .PriorityClassName }}
+      priorityClassName: {{ . }}
+{{- end }}
+{{- with .PodSecurityContext }}
+      securityContext:
+{{ toYaml . | indent 8 }}
+{{- end }}
     {{- with .PullSecrets }}
       imagePullSecrets:
       {{range $val := .}}
@@ -81,8 +88,13 @@ spec:
       {{- end }}
       containers:
       - name: scheduler
+{{- if .SecurityContext }}
+        securityContext:
+{{ toYaml .SecurityContext | indent 10 }}
+{{- else }}
         securityContext:
           runAsUser: 0
+{{- end }}
         image: "{{ .Image }}"
         imagePullPolicy: {{ .PullPolicy }}
         command:
@@ -93,11 +105,23 @@ spec:
               fieldRef:
                 fieldPath: status.podIP
           - name: DASK_SCHEDULER
-            value: "dask-scheduler-{{ .Cluster }}.{{ .Namespace }}:{{ .Port }}"
+            value: "{{ if .TLS }}tls://{{ end }}dask-scheduler-{{ .Cluster }}.{{ .Namespace }}:{{ .Port }}"
+{{- if .TLS }}
+          - name: DASK_TLS_DIR
+            value: /etc/dask/tls
+{{- end }}
           - name: DASK_PORT_SCHEDULER
             value: "{{ .Port }}"
           - name: DASK_LOCAL_DIRECTORY
             value: "/var/tmp"
+{{- if .Restricted }}
+          - name: HOME
+            value: /home/dask
+{{- if not .Report }}
+          - name: REPORTS_DIR
+            value: /var/tmp/reports
+{{- end }}
+{{- end }}
           - name: K8S_APP_NAME
             valueFrom:
               fieldRef:
@@ -116,9 +140,25 @@ spec:
         - mountPath: /app.{{ .ScriptType }}
           subPath: app.{{ .ScriptType }}
           name: dask-script
+{{- if .DaskConfig }}
+        - mountPath: /etc/dask/dask.yaml
+          subPath: dask.yaml
+          name: dask-script
+{{- end }}
         - mountPath: /var/tmp
           readOnly: false
           name: localdir
+{{- if .Restricted }}
+        - mountPath: /home/dask
+          name: home
+        - mountPath: /tmp
+          name: tmp
+{{- end }}
+{{- if .TLS }}
+        - mountPath: /etc/dask/tls
+          readOnly: true
+          name: dask-tls
+{{- end }}
 {{- with .VolumeMounts }}
 {{ toYaml . | indent 8 }}
 {{- end }}
@@ -134,6 +174,17 @@ spec:
         name: dask-script
       - name: localdir
         emptyDir: {}
+{{- if .Restricted }}
+      - name: home
+        emptyDir: {}
+      - name: tmp
+        emptyDir: {}
+{{- end }}
+{{- if .TLS }}
+      - name: dask-tls
+        secret:
+          secretName: dask-tls-{{ .Cluster }}-client
+{{- end }}
 {{- with .Volumes }}
 {{ toYaml . | indent 6 }}
 {{- end }}
@@ -162,5 +213,8 @@ spec:
 	if err := json.Unmarshal([]byte(result), job); err != nil {
 		return nil, err
 	}
+	if err := applyPodTemplate(&job.Spec.Template, dcontext.PodTemplate); err != nil {
+		return nil, err
+	}
 	return job, err
 }
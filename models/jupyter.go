@@ -25,16 +25,27 @@ metadata:
     app.kubernetes.io/name: jupyter-notebook
     app.kubernetes.io/instance: "{{ .Name }}"
     app.kubernetes.io/managed-by: DaskController
+{{- with .ServiceAnnotations }}
+  annotations:
+{{ toYaml . | indent 4 }}
+{{- end }}
 spec:
   selector:
     app.kubernetes.io/name:  jupyter-notebook
     app.kubernetes.io/instance: "{{ .Name }}"
   type: {{ .ServiceType }}
+{{- if and (eq .ServiceType "LoadBalancer") .SourceRanges }}
+  loadBalancerSourceRanges:
+{{ toYaml .SourceRanges | indent 2 }}
+{{- end }}
   ports:
   - name: jupyter
     port: 8888
     targetPort: jupyter
     protocol: TCP
+{{- with and (ne .ServiceType "ClusterIP") (index .NodePorts "jupyter") }}
+    nodePort: {{ . }}
+{{- end }}
 `
 	result, err := utils.ApplyTemplate(jupyterService, dcontext)
 	if err != nil {
@@ -67,15 +78,31 @@ spec:
     matchLabels:
       app.kubernetes.io/name: jupyter-notebook
       app.kubernetes.io/instance: "{{ .Name }}"
-  replicas: 1
+  replicas: {{ if or .Suspended (and .Hibernated .HibernateNotebook) }}0{{ else }}1{{ end }}
   template:
     metadata:
+{{- if or .TLSVersion .DaskConfig }}
+      annotations:
+{{- with .TLSVersion }}
+        analytics.piersharding.com/tls-version: "{{ . }}"
+{{- end }}
+{{- with .DaskConfig }}
+        analytics.piersharding.com/dask-config: "{{ toYaml . | sha256sum | trunc 16 }}"
+{{- end }}
+{{- end }}
       labels:
         app.kubernetes.io/name: jupyter-notebook
         app.kubernetes.io/instance: "{{ .Name }}"
         app.kubernetes.io/managed-by: DaskController
     spec:
       serviceAccountName: "dask-cluster-serviceaccount-{{ .Name }}"
+{{- with .PriorityClassName }}
+      priorityClassName: {{ . }}
+{{- end }}
+{{- with .PodSecurityContext }}
+      securityContext:
+{{ toYaml . | indent 8 }}
+{{- end }}
     {{- with .PullSecrets }}
       imagePullSecrets:
       {{range $val := .}}
@@ -86,17 +113,55 @@ spec:
       - name: jupyter
         image: "{{ .JupyterImage }}"
         imagePullPolicy: {{ .PullPolicy }}
+{{- if .SecurityContext }}
+        securityContext:
+{{ toYaml .SecurityContext | indent 10 }}
+{{- else }}
         securityContext:
           runAsUser: 0
+{{- end }}
         command:
+{{- with .Command }}
+{{ toYaml . | indent 10 }}
+{{- else }}
           - /start-jupyter-notebook.sh
+{{- end }}
+{{- with .Args }}
+        args:
+{{ toYaml . | indent 10 }}
+{{- end }}
         env:
           - name: DASK_SCHEDULER
-            value: dask-scheduler-{{ .Name }}.{{ .Namespace }}:8786
-          - name: JUPYTER_PASSWORD
-            value: "{{ .JupyterPassword }}"
+            value: {{ if .TLS }}tls://{{ end }}dask-scheduler-{{ .Name }}.{{ .Namespace }}:8786
+{{- if .TLS }}
+          - name: DASK_TLS_DIR
+            value: /etc/dask/tls
+{{- end }}
+          - name: {{ if eq .JupyterAuthType "token" }}JUPYTER_TOKEN{{ else if .JupyterAuthHashed }}JUPYTER_PASSWORD_HASH{{ else }}JUPYTER_PASSWORD{{ end }}
+            valueFrom:
+              secretKeyRef:
+                name: "{{ .JupyterAuthSecret }}"
+                key: "{{ .JupyterAuthKey }}"
           - name: NOTEBOOK_PORT
             value: "8888"
+{{- if .Restricted }}
+          - name: HOME
+            value: /home/dask
+{{- end }}
+          - name: NOTEBOOK_MODE
+            value: "{{ .NotebookMode }}"
+          - name: NOTEBOOK_BASE_URL
+            value: "{{ .NotebookBaseURL }}"
+{{- if eq .NotebookMode "lab" }}
+          - name: DASK_SCHEDULER_ADDRESS
+            value: {{ if .TLS }}tls://{{ end }}dask-scheduler-{{ .Name }}.{{ .Namespace }}:8786
+          # the dashboard as the browser reaches it - through the Ingress or
+          # HTTPRoute, or else through jupyter-server-proxy
+          - name: DASK_DASHBOARD_URL
+            value: {{ with .DashboardURL }}{{ . }}{{ else }}{{ .NotebookBaseURL }}proxy/dask-scheduler-{{ .Name }}.{{ .Namespace }}:{{ .BokehPort }}/status{{ end }}
+          - name: DASK_DASHBOARD_HOST
+            value: dask-scheduler-{{ .Name }}.{{ .Namespace }}
+{{- end }}
 {{- with .Env }}
 {{ toYaml . | indent 10 }}
 {{- end }}
@@ -104,21 +169,45 @@ spec:
         - name: jupyter
           containerPort: 8888
         volumeMounts:
+{{- with .StartScript }}
+        - mountPath: /start-jupyter-notebook.sh
+          subPath: {{ .key }}
+          name: start-script
+{{- else }}
         - mountPath: /start-jupyter-notebook.sh
           subPath: start-jupyter-notebook.sh
           name: dask-script
+{{- end }}
+{{- if .DaskConfig }}
+        - mountPath: /etc/dask/dask.yaml
+          subPath: {{ .DaskConfigKey }}
+          name: dask-script
+{{- end }}
         - mountPath: /jupyter_notebook_config.py
           subPath: jupyter_notebook_config.py
           name: dask-script
+{{- if .TLS }}
+        - mountPath: /etc/dask/tls
+          readOnly: true
+          name: dask-tls
+{{- end }}
         - mountPath: /var/tmp
           readOnly: false
           name: localdir
+{{- if .Restricted }}
+        - mountPath: /app
+          name: app
+        - mountPath: /home/dask
+          name: home
+        - mountPath: /tmp
+          name: tmp
+{{- end }}
 {{- with .VolumeMounts }}
 {{ toYaml . | indent 8 }}
 {{- end }}
         readinessProbe:
           httpGet:
-            path: /api
+            path: {{ .NotebookBaseURL }}api
             port: 8888
           initialDelaySeconds: 10
           timeoutSeconds: 10
@@ -129,10 +218,32 @@ spec:
           name: dask-configs-{{ .Name }}
           defaultMode: 0777
         name: dask-script
+{{- with .StartScript }}
+      - configMap:
+          name: {{ .name }}
+          defaultMode: 0777
+        name: start-script
+{{- end }}
+{{- if .Restricted }}
+      - name: localdir
+        emptyDir: {}
+      - name: app
+        emptyDir: {}
+      - name: home
+        emptyDir: {}
+      - name: tmp
+        emptyDir: {}
+{{- else }}
       - hostPath:
           path: /var/tmp
           type: DirectoryOrCreate
         name: localdir
+{{- end }}
+{{- if .TLS }}
+      - name: dask-tls
+        secret:
+          secretName: dask-tls-{{ .Name }}-client
+{{- end }}
 
 {{- with .Volumes }}
 {{ toYaml . | indent 6 }}
@@ -162,6 +273,9 @@ spec:
 	if err := json.Unmarshal([]byte(result), deployment); err != nil {
 		return nil, err
 	}
+	if err := applyPodTemplate(&deployment.Spec.Template, dcontext.PodTemplate); err != nil {
+		return nil, err
+	}
 	return deployment, err
 }
 
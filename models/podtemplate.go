@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// applyPodTemplate strategically merges the podTemplate given for a
+// component over the Pod template generated for it, so containers are
+// matched up by name, and so on
+func applyPodTemplate(template *corev1.PodTemplateSpec, podTemplate interface{}) error {
+	if podTemplate == nil {
+		return nil
+	}
+	patch, err := json.Marshal(podTemplate)
+	if err != nil {
+		return err
+	}
+	original, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, corev1.PodTemplateSpec{})
+	if err != nil {
+		return err
+	}
+	*template = corev1.PodTemplateSpec{}
+	return json.Unmarshal(merged, template)
+}
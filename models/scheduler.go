@@ -25,20 +25,34 @@ metadata:
     app.kubernetes.io/name: dask-scheduler
     app.kubernetes.io/instance: "{{ .Name }}"
     app.kubernetes.io/managed-by: DaskController
+{{- with .ServiceAnnotations }}
+  annotations:
+{{ toYaml . | indent 4 }}
+{{- end }}
 spec:
   selector:
     app.kubernetes.io/name:  dask-scheduler
     app.kubernetes.io/instance: "{{ .Name }}"
   type: {{ .ServiceType }}
+{{- if and (eq .ServiceType "LoadBalancer") .SourceRanges }}
+  loadBalancerSourceRanges:
+{{ toYaml .SourceRanges | indent 2 }}
+{{- end }}
   ports:
   - name: scheduler
     port: {{ .Port }}
     targetPort: scheduler
     protocol: TCP
+{{- with and (ne .ServiceType "ClusterIP") (index .NodePorts "scheduler") }}
+    nodePort: {{ . }}
+{{- end }}
   - name: bokeh
     port: {{ .BokehPort }}
     targetPort: bokeh
     protocol: TCP
+{{- with and (ne .ServiceType "ClusterIP") (index .NodePorts "bokeh") }}
+    nodePort: {{ . }}
+{{- end }}
 `
 	result, err := utils.ApplyTemplate(schedulerService, dcontext)
 	if err != nil {
@@ -71,15 +85,31 @@ spec:
     matchLabels:
       app.kubernetes.io/name: dask-scheduler
       app.kubernetes.io/instance: "{{ .Name }}"
-  replicas: 1
+  replicas: {{ if .Suspended }}0{{ else }}1{{ end }}
   template:
     metadata:
+{{- if or .TLSVersion .DaskConfig }}
+      annotations:
+{{- with .TLSVersion }}
+        analytics.piersharding.com/tls-version: "{{ . }}"
+{{- end }}
+{{- with .DaskConfig }}
+        analytics.piersharding.com/dask-config: "{{ toYaml . | sha256sum | trunc 16 }}"
+{{- end }}
+{{- end }}
       labels:
         app.kubernetes.io/name: dask-scheduler
         app.kubernetes.io/instance: "{{ .Name }}"
         app.kubernetes.io/managed-by: DaskController
     spec:
       serviceAccountName: "dask-cluster-serviceaccount-{{ .Name }}"
+{{- with .PriorityClassName }}
+      priorityClassName: {{ . }}
+{{- end }}
+{{- with .PodSecurityContext }}
+      securityContext:
+{{ toYaml . | indent 8 }}
+{{- end }}
     {{- with .PullSecrets }}
       imagePullSecrets:
       {{range $val := .}}
@@ -90,8 +120,20 @@ spec:
       - name: scheduler
         image: "{{ .Image }}"
         imagePullPolicy: {{ .PullPolicy }}
+{{- with .SecurityContext }}
+        securityContext:
+{{ toYaml . | indent 10 }}
+{{- end }}
         command:
+{{- with .Command }}
+{{ toYaml . | indent 10 }}
+{{- else }}
           - /start-dask-scheduler.sh
+{{- end }}
+{{- with .Args }}
+        args:
+{{ toYaml . | indent 10 }}
+{{- end }}
         env:
           - name: DASK_HOST_NAME
             valueFrom:
@@ -109,6 +151,14 @@ spec:
             value: "/"
           - name: DASK_LOCAL_DIRECTORY
             value: "/var/tmp"
+{{- if .Restricted }}
+          - name: HOME
+            value: /home/dask
+{{- end }}
+{{- if .TLS }}
+          - name: DASK_TLS_DIR
+            value: /etc/dask/tls
+{{- end }}
           - name: K8S_APP_NAME
             valueFrom:
               fieldRef:
@@ -140,12 +190,34 @@ spec:
         - name: bokeh
           containerPort: {{ .BokehPort }}
         volumeMounts:
+{{- with .StartScript }}
+        - mountPath: /start-dask-scheduler.sh
+          subPath: {{ .key }}
+          name: start-script
+{{- else }}
         - mountPath: /start-dask-scheduler.sh
           subPath: start-dask-scheduler.sh
           name: dask-script
+{{- end }}
+{{- if .DaskConfig }}
+        - mountPath: /etc/dask/dask.yaml
+          subPath: {{ .DaskConfigKey }}
+          name: dask-script
+{{- end }}
+{{- if .TLS }}
+        - mountPath: /etc/dask/tls
+          readOnly: true
+          name: dask-tls
+{{- end }}
         - mountPath: /var/tmp
           readOnly: false
           name: localdir
+{{- if .Restricted }}
+        - mountPath: /home/dask
+          name: home
+        - mountPath: /tmp
+          name: tmp
+{{- end }}
 {{- with .VolumeMounts }}
 {{ toYaml . | indent 8 }}
 {{- end }}
@@ -162,12 +234,29 @@ spec:
           name: dask-configs-{{ .Name }}
           defaultMode: 0777
         name: dask-script
+{{- with .StartScript }}
+      - configMap:
+          name: {{ .name }}
+          defaultMode: 0777
+        name: start-script
+{{- end }}
       #- hostPath:
       #    path: /var/tmp
       #    type: DirectoryOrCreate
       #  name: localdir
       - name: localdir
         emptyDir: {}
+{{- if .Restricted }}
+      - name: home
+        emptyDir: {}
+      - name: tmp
+        emptyDir: {}
+{{- end }}
+{{- if .TLS }}
+      - name: dask-tls
+        secret:
+          secretName: dask-tls-{{ .Name }}-scheduler
+{{- end }}
 {{- with .Volumes }}
 {{ toYaml . | indent 6 }}
 {{- end }}
@@ -196,6 +285,9 @@ spec:
 	if err := json.Unmarshal([]byte(result), deployment); err != nil {
 		return nil, err
 	}
+	if err := applyPodTemplate(&deployment.Spec.Template, dcontext.PodTemplate); err != nil {
+		return nil, err
+	}
 	return deployment, err
 }
 
@@ -228,11 +320,6 @@ spec:
           app.kubernetes.io/name:  dask-worker
           app.kubernetes.io/instance: "{{ .Name }}"
     - podSelector:
-    # enable the scheduler interface for the notebook
-        matchLabels:
-          app.kubernetes.io/name:  jupyter-notebook
-          app.kubernetes.io/instance: "{{ .Name }}"
-    - podSelector:
     # enable the scheduler interface for any DaskJob
         matchLabels:
           app.kubernetes.io/managed-by: DaskJobController
@@ -240,6 +327,18 @@ spec:
     ports:
     - port: scheduler
       protocol: TCP
+  - from:
+    - podSelector:
+    # enable the scheduler and monitor interfaces for the notebook, which
+    # proxies the dashboard
+        matchLabels:
+          app.kubernetes.io/name:  jupyter-notebook
+          app.kubernetes.io/instance: "{{ .Name }}"
+    ports:
+    - port: scheduler
+      protocol: TCP
+    - port: bokeh
+      protocol: TCP
   - from:
     - namespaceSelector: {}
       podSelector:
@@ -250,6 +349,27 @@ spec:
     ports:
     - port: bokeh
       protocol: TCP
+  - from:
+    - namespaceSelector: {}
+      podSelector:
+    # enable the scheduler monitor interface for the operator, to read the
+    # load and to retire workers
+        matchLabels:
+          control-plane: controller-manager
+    ports:
+    - port: bokeh
+      protocol: TCP
+{{- if .Gateway }}
+  # enable the scheduler monitor interface for the Gateway behind the
+  # dashboard HTTPRoute
+  - ports:
+    - port: bokeh
+      protocol: TCP
+{{- with .GatewayFrom }}
+    from:
+{{ toYaml . | indent 4 }}
+{{- end }}
+{{- end }}
   egress:
   - to:
     - podSelector:
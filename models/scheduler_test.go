@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	dtypes "gitlab.com/piersharding/dask-operator/types"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// admittedPorts lists the ports that the rules of a NetworkPolicy open to
+// the Pods with the given labels
+func admittedPorts(policy *networkingv1.NetworkPolicy, podLabels map[string]string) []string {
+	var ports []string
+	for _, rule := range policy.Spec.Ingress {
+		admitted := len(rule.From) == 0
+		for _, peer := range rule.From {
+			if peer.PodSelector == nil {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+			Expect(err).NotTo(HaveOccurred())
+			admitted = admitted || selector.Matches(labels.Set(podLabels))
+		}
+		if !admitted {
+			continue
+		}
+		for _, port := range rule.Ports {
+			ports = append(ports, port.Port.String())
+		}
+	}
+	return ports
+}
+
+var _ = Describe("Scheduler NetworkPolicy", func() {
+
+	var dask analyticsv1.Dask
+
+	BeforeEach(func() {
+		dask = analyticsv1.Dask{ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "dask"}}
+	})
+
+	render := func() *networkingv1.NetworkPolicy {
+		policy, err := DaskSchedulerNetworkPolicy(dtypes.SetConfig(dask))
+		Expect(err).NotTo(HaveOccurred())
+		return policy
+	}
+
+	It("should let the operator reach the dashboard of a Dask that is not adaptive", func() {
+		Expect(dask.Spec.Adaptive).To(BeNil())
+		Expect(admittedPorts(render(), map[string]string{"control-plane": "controller-manager"})).To(ContainElement("bokeh"))
+	})
+
+	It("should let the notebook reach the scheduler and proxy the dashboard", func() {
+		notebook := map[string]string{"app.kubernetes.io/name": "jupyter-notebook", "app.kubernetes.io/instance": "app-1"}
+		Expect(admittedPorts(render(), notebook)).To(ContainElements("scheduler", "bokeh"))
+	})
+
+	It("should not let the notebook of another Dask reach the dashboard", func() {
+		notebook := map[string]string{"app.kubernetes.io/name": "jupyter-notebook", "app.kubernetes.io/instance": "app-2"}
+		Expect(admittedPorts(render(), notebook)).NotTo(ContainElement("bokeh"))
+	})
+
+	Context("with a Gateway", func() {
+		gateway := map[string]string{"app.kubernetes.io/name": "envoy"}
+
+		BeforeEach(func() {
+			dask.Spec.Gateway = &analyticsv1.DaskGatewaySpec{ParentRef: analyticsv1.DaskGatewayParentRef{Name: "shared-gateway"}}
+		})
+
+		It("should let the Gateway reach the dashboard", func() {
+			Expect(admittedPorts(render(), gateway)).To(ContainElement("bokeh"))
+		})
+
+		It("should only let the given Gateway Pods reach the dashboard", func() {
+			dask.Spec.Gateway.From = []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{},
+				PodSelector:       &metav1.LabelSelector{MatchLabels: gateway},
+			}}
+			policy := render()
+			Expect(admittedPorts(policy, gateway)).To(ContainElement("bokeh"))
+			Expect(admittedPorts(policy, map[string]string{"app": "other"})).NotTo(ContainElement("bokeh"))
+		})
+
+		It("should take the Gateway Pods from the class", func() {
+			class := &analyticsv1.DaskClusterClass{Spec: analyticsv1.DaskClusterClassSpec{
+				GatewayFrom: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: gateway}}},
+			}}
+			policy, err := DaskSchedulerNetworkPolicy(dtypes.SetClassConfig(dask, class))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(admittedPorts(policy, gateway)).To(ContainElement("bokeh"))
+			Expect(admittedPorts(policy, map[string]string{"app": "other"})).NotTo(ContainElement("bokeh"))
+		})
+	})
+
+	It("should not open the dashboard to other Pods without a Gateway", func() {
+		Expect(admittedPorts(render(), map[string]string{"app.kubernetes.io/name": "envoy"})).NotTo(ContainElement("bokeh"))
+	})
+
+	It("should let the operator read the load of an idle Dask that is not adaptive", func() {
+		dask.Spec.IdleTimeout = &metav1.Duration{Duration: time.Hour}
+		Expect(admittedPorts(render(), map[string]string{"control-plane": "controller-manager"})).To(ContainElement("bokeh"))
+	})
+})
+
+var _ = Describe("Scheduler Deployment", func() {
+	It("should leave the adaptive target to the operator", func() {
+		dask := analyticsv1.Dask{ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "dask"}}
+		dask.Spec.Adaptive = &analyticsv1.DaskAdaptiveSpec{Maximum: 10}
+		deployment, err := DaskSchedulerDeployment(dtypes.SetConfig(dask))
+		Expect(err).NotTo(HaveOccurred())
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			Expect(env.Name).NotTo(HavePrefix("DASK_DISTRIBUTED__ADAPTIVE__"))
+		}
+	})
+})
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestModels(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Models Suite")
+}
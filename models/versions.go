@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// daskLine - how a line of Dask releases is started from the command line
+type daskLine struct {
+	// Since is the first release of the line
+	Since string
+	// Scheduler and Worker are the commands that start them
+	Scheduler string
+	Worker    string
+	// Processes is the worker flag for the number of processes in each Pod
+	Processes string
+	// Legacy Schedulers take --use-xheaders and --local-directory
+	Legacy bool
+}
+
+// daskLines - the supported lines of Dask releases, newest first
+var daskLines = []daskLine{
+	// the dask CLI, that deprecates dask-scheduler and dask-worker
+	{Since: "2022.10", Scheduler: "dask scheduler", Worker: "dask worker", Processes: "--nworkers"},
+	// --nprocs renamed --nworkers
+	{Since: "2021.12", Scheduler: "dask-scheduler", Worker: "dask-worker", Processes: "--nworkers", Legacy: true},
+	// 2.x, and the calendar versioned releases before 2021.12
+	{Since: "0", Scheduler: "dask-scheduler", Worker: "dask-worker", Processes: "--nprocs", Legacy: true},
+}
+
+// daskLinesFor picks the line of releases for the given Dask version - when
+// it is not known, all of the lines are given for the start scripts to
+// choose from once they have found the version in the image
+func daskLinesFor(version string) []daskLine {
+	if version == "" {
+		return daskLines
+	}
+	for _, line := range daskLines {
+		if versionAtLeast(version, line.Since) {
+			return []daskLine{line}
+		}
+	}
+	return daskLines[len(daskLines)-1:]
+}
+
+// versionAtLeast compares dotted versions part by part, as sort -V does -
+// anything after the leading digits of a part, such as rc1, is ignored
+func versionAtLeast(version, since string) bool {
+	parts := func(v string) []int {
+		var out []int
+		for _, part := range strings.Split(v, ".") {
+			end := 0
+			for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+				end++
+			}
+			n, _ := strconv.Atoi(part[:end])
+			out = append(out, n)
+		}
+		return out
+	}
+	a, b := parts(version), parts(since)
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dask release lines", func() {
+
+	table.DescribeTable("should choose the command line for the Dask version",
+		func(version string, scheduler string, worker string, processes string, legacy bool) {
+			lines := daskLinesFor(version)
+			Expect(lines).To(HaveLen(1))
+			Expect(lines[0].Scheduler).To(Equal(scheduler))
+			Expect(lines[0].Worker).To(Equal(worker))
+			Expect(lines[0].Processes).To(Equal(processes))
+			Expect(lines[0].Legacy).To(Equal(legacy))
+		},
+		table.Entry("2.x", "2.9.0", "dask-scheduler", "dask-worker", "--nprocs", true),
+		table.Entry("before --nworkers", "2021.11.2", "dask-scheduler", "dask-worker", "--nprocs", true),
+		table.Entry("--nworkers", "2021.12.0", "dask-scheduler", "dask-worker", "--nworkers", true),
+		table.Entry("before the dask CLI", "2022.9.2", "dask-scheduler", "dask-worker", "--nworkers", true),
+		table.Entry("the dask CLI", "2022.10.0", "dask scheduler", "dask worker", "--nworkers", false),
+		table.Entry("the dask CLI, by major and minor", "2024.1", "dask scheduler", "dask worker", "--nworkers", false),
+	)
+
+	It("should leave every line for the start scripts when the version is not known", func() {
+		Expect(daskLinesFor("")).To(Equal(daskLines))
+	})
+
+	table.DescribeTable("should compare versions part by part",
+		func(version string, since string, atLeast bool) {
+			Expect(versionAtLeast(version, since)).To(Equal(atLeast))
+		},
+		table.Entry("newer minor", "2021.12.0", "2021.9", true),
+		table.Entry("older minor", "2021.9.0", "2021.12", false),
+		table.Entry("equal, with fewer parts", "2022.10", "2022.10.0", true),
+		table.Entry("pre-release suffix", "2022.10.0rc1", "2022.10", true),
+		table.Entry("any version", "2.9.0", "0", true),
+	)
+})
@@ -7,36 +7,66 @@ import (
 	dtypes "gitlab.com/piersharding/dask-operator/types"
 	"gitlab.com/piersharding/dask-operator/utils"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 )
 
-// DaskWorkerDeployment generates the Deployment description for
-// the Dask Worker
-func DaskWorkerDeployment(dcontext dtypes.DaskContext) (*appsv1.Deployment, error) {
-	const workerDeployment = `
+// daskWorker renders the workers, in the form of a Deployment or, with
+// stable names and a volume each, a StatefulSet
+func daskWorker(dcontext dtypes.DaskContext) (string, error) {
+	const daskWorker = `
 apiVersion: apps/v1
-kind: Deployment
+kind: {{ if .WorkerStatefulSet }}StatefulSet{{ else }}Deployment{{ end }}
 metadata:
-  name: dask-worker-{{ .Name }}
+  name: dask-worker-{{ .Name }}{{ with .WorkerGroup }}-{{ . }}{{ end }}
   namespace: {{ .Namespace }}
   labels:
     app.kubernetes.io/name: dask-worker
     app.kubernetes.io/instance: "{{ .Name }}"
     app.kubernetes.io/managed-by: DaskController
+{{- with .WorkerGroup }}
+    analytics.piersharding.com/worker-group: "{{ . }}"
+{{- end }}
 spec:
   selector:
     matchLabels:
       app.kubernetes.io/name: dask-worker
       app.kubernetes.io/instance: "{{ .Name }}"
+{{- with .WorkerGroup }}
+      analytics.piersharding.com/worker-group: "{{ . }}"
+{{- end }}
   replicas: {{ .Replicas }}
+{{- if .WorkerStatefulSet }}
+  serviceName: dask-worker-{{ .Name }}
+  podManagementPolicy: Parallel
+{{- end }}
   template:
     metadata:
+{{- if or .TLSVersion .DaskConfig }}
+      annotations:
+{{- with .TLSVersion }}
+        analytics.piersharding.com/tls-version: "{{ . }}"
+{{- end }}
+{{- with .DaskConfig }}
+        analytics.piersharding.com/dask-config: "{{ toYaml . | sha256sum | trunc 16 }}"
+{{- end }}
+{{- end }}
       labels:
         app.kubernetes.io/name: dask-worker
         app.kubernetes.io/instance: "{{ .Name }}"
         app.kubernetes.io/managed-by: DaskController
+{{- with .WorkerGroup }}
+        analytics.piersharding.com/worker-group: "{{ . }}"
+{{- end }}
     spec:
       serviceAccountName: "dask-cluster-serviceaccount-{{ .Name }}"
+{{- with .PriorityClassName }}
+      priorityClassName: {{ . }}
+{{- end }}
+{{- with .PodSecurityContext }}
+      securityContext:
+{{ toYaml . | indent 8 }}
+{{- end }}
       {{- with .PullSecrets }}
       imagePullSecrets:
       {{range $val := .}}
@@ -47,6 +77,10 @@ spec:
       - name: worker
         image: "{{ .Image }}"
         imagePullPolicy: {{ .PullPolicy }}
+{{- with .SecurityContext }}
+        securityContext:
+{{ toYaml . | indent 10 }}
+{{- end }}
 {{- if .Resources -}}
 {{- with .Resources }}
         resources:
@@ -54,7 +88,15 @@ spec:
 {{- end }}
 {{- end }}
         command:
+{{- with .Command }}
+{{ toYaml . | indent 10 }}
+{{- else }}
           - /start-dask-worker.sh
+{{- end }}
+{{- with .Args }}
+        args:
+{{ toYaml . | indent 10 }}
+{{- end }}
         env:
           - name: DASK_HOST_NAME
             valueFrom:
@@ -72,8 +114,16 @@ spec:
             value: ":{{ .BokehPort }}"
           - name: DASK_LOCAL_DIRECTORY
             value: "/var/tmp"
+{{- if .Restricted }}
+          - name: HOME
+            value: /home/dask
+{{- end }}
+{{- if .TLS }}
+          - name: DASK_TLS_DIR
+            value: /etc/dask/tls
+{{- end }}
           - name: DASK_RESOURCES
-            value: ""
+            value: "{{ .DaskResources }}"
           - name: K8S_APP_NAME
             valueFrom:
               fieldRef:
@@ -86,16 +136,35 @@ spec:
             valueFrom:
               fieldRef:
                 fieldPath: metadata.name
-          - name: DASK_CPU_LIMIT
+{{- if .WorkerStatefulSet }}
+          - name: DASK_WORKER_NAME
+            valueFrom:
+              fieldRef:
+                fieldPath: metadata.name
+{{- end }}
+{{- with .CPUResource }}
+          - name: DASK_CPU_MILLICORES
             valueFrom:
               resourceFieldRef:
                 containerName: worker
-                resource: limits.cpu
-          - name: DASK_MEM_LIMIT
+                resource: {{ . }}
+                divisor: 1m
+{{- end }}
+{{- with .MemoryResource }}
+          - name: DASK_MEMORY_BYTES
             valueFrom:
               resourceFieldRef:
                 containerName: worker
-                resource: limits.memory
+                resource: {{ . }}
+{{- end }}
+          - name: DASK_NWORKERS
+            value: "{{ .NWorkers }}"
+{{- with .NThreads }}
+          - name: DASK_NTHREADS
+            value: "{{ . }}"
+{{- end }}
+          - name: DASK_MEMORY_FRACTION
+            value: "{{ .MemoryFraction }}"
 {{- with .Env }}
 {{ toYaml . | indent 10 }}
 {{- end }}
@@ -112,12 +181,34 @@ spec:
           periodSeconds: 20
           failureThreshold: 3
         volumeMounts:
+{{- with .StartScript }}
+        - mountPath: /start-dask-worker.sh
+          subPath: {{ .key }}
+          name: start-script
+{{- else }}
         - mountPath: /start-dask-worker.sh
           subPath: start-dask-worker.sh
           name: dask-script
+{{- end }}
+{{- if .DaskConfig }}
+        - mountPath: /etc/dask/dask.yaml
+          subPath: {{ .DaskConfigKey }}
+          name: dask-script
+{{- end }}
+{{- if .TLS }}
+        - mountPath: /etc/dask/tls
+          readOnly: true
+          name: dask-tls
+{{- end }}
         - mountPath: /var/tmp
           readOnly: false
           name: localdir
+{{- if .Restricted }}
+        - mountPath: /home/dask
+          name: home
+        - mountPath: /tmp
+          name: tmp
+{{- end }}
 {{- with .VolumeMounts }}
 {{ toYaml . | indent 8 }}
 {{- end }}
@@ -126,12 +217,31 @@ spec:
           name: dask-configs-{{ .Name }}
           defaultMode: 0777
         name: dask-script
+{{- with .StartScript }}
+      - configMap:
+          name: {{ .name }}
+          defaultMode: 0777
+        name: start-script
+{{- end }}
       #- hostPath:
       #    path: /var/tmp
       #    type: DirectoryOrCreate
       #  name: localdir
+{{- if not .WorkerStatefulSet }}
       - name: localdir
         emptyDir: {}
+{{- end }}
+{{- if .Restricted }}
+      - name: home
+        emptyDir: {}
+      - name: tmp
+        emptyDir: {}
+{{- end }}
+{{- if .TLS }}
+      - name: dask-tls
+        secret:
+          secretName: dask-tls-{{ .Name }}-worker
+{{- end }}
 {{- with .Volumes }}
 {{ toYaml . | indent 6 }}
 {{- end }}
@@ -154,13 +264,43 @@ spec:
       tolerations:
 {{ toYaml . | indent 8 }}
 {{- end }}
+{{- if .WorkerStatefulSet }}
+  volumeClaimTemplates:
+  - metadata:
+      name: localdir
+      labels:
+        app.kubernetes.io/name: dask-worker
+        app.kubernetes.io/instance: "{{ .Name }}"
+        app.kubernetes.io/managed-by: DaskController
+    spec:
+      accessModes: [ReadWriteOnce]
+{{- with .WorkerStorageClass }}
+      storageClassName: {{ . }}
+{{- end }}
+      resources:
+        requests:
+          storage: {{ .WorkerStorageSize }}
+{{- end }}
 `
 	if dcontext.Daemon {
 		log.Infof("Adding Daemon affinity rules")
-		if dcontext.Affinity == nil {
+		// wash the affinity through JSON, as it may be a typed (even nil)
+		// *corev1.Affinity, and must not be shared with other resources
+		affinity := map[string]interface{}{}
+		if dcontext.Affinity != nil {
+			byt, err := json.Marshal(dcontext.Affinity)
+			if err != nil {
+				return "", err
+			}
+			if err := json.Unmarshal(byt, &affinity); err != nil {
+				return "", err
+			}
+		}
+		if affinity == nil {
 			log.Debugf("dcontext.Affinity.podAntiAffinity does not exist")
-			dcontext.Affinity = map[string]interface{}{}
+			affinity = map[string]interface{}{}
 		}
+		dcontext.Affinity = affinity
 		if _, ok := dcontext.Affinity.(map[string]interface{})["podAntiAffinity"]; !ok {
 			log.Debugf("dcontext.Affinity.podAntiAffinity does not exist")
 			dcontext.Affinity.(map[string]interface{})["podAntiAffinity"] = map[string]interface{}{}
@@ -183,9 +323,52 @@ spec:
 					"topologyKey": "kubernetes.io/hostname"})
 	}
 
-	result, err := utils.ApplyTemplate(workerDeployment, dcontext)
+	cpu, memory, err := resourceSources(dcontext.Resources)
+	if err != nil {
+		return "", err
+	}
+	dcontext.CPUResource = cpu
+	dcontext.MemoryResource = memory
+
+	result, err := utils.ApplyTemplate(daskWorker, dcontext)
 	if err != nil {
 		log.Debugf("ApplyTemplate Error: %+v\n", err)
+	}
+	return result, err
+}
+
+// resourceSources picks the limits, or failing those the requests, that
+// the worker threads and memory limit are worked out from - none when
+// neither is set, so that dask-worker falls back on its own defaults
+func resourceSources(resources interface{}) (string, string, error) {
+	requirements := corev1.ResourceRequirements{}
+	if resources != nil {
+		byt, err := json.Marshal(resources)
+		if err != nil {
+			return "", "", err
+		}
+		if err := json.Unmarshal(byt, &requirements); err != nil {
+			return "", "", err
+		}
+	}
+	source := func(name corev1.ResourceName) string {
+		if _, ok := requirements.Limits[name]; ok {
+			return "limits." + string(name)
+		}
+		if _, ok := requirements.Requests[name]; ok {
+			return "requests." + string(name)
+		}
+		return ""
+	}
+	return source(corev1.ResourceCPU), source(corev1.ResourceMemory), nil
+}
+
+// DaskWorkerDeployment generates the Deployment description for
+// the Dask Worker
+func DaskWorkerDeployment(dcontext dtypes.DaskContext) (*appsv1.Deployment, error) {
+	dcontext.WorkerStatefulSet = false
+	result, err := daskWorker(dcontext)
+	if err != nil {
 		return nil, err
 	}
 
@@ -193,9 +376,69 @@ spec:
 	if err := json.Unmarshal([]byte(result), deployment); err != nil {
 		return nil, err
 	}
+	if err := applyPodTemplate(&deployment.Spec.Template, dcontext.PodTemplate); err != nil {
+		return nil, err
+	}
 	return deployment, err
 }
 
+// DaskWorkerStatefulSet generates the StatefulSet description for
+// the Dask Worker
+func DaskWorkerStatefulSet(dcontext dtypes.DaskContext) (*appsv1.StatefulSet, error) {
+	dcontext.WorkerStatefulSet = true
+	result, err := daskWorker(dcontext)
+	if err != nil {
+		return nil, err
+	}
+
+	statefulset := &appsv1.StatefulSet{}
+	if err := json.Unmarshal([]byte(result), statefulset); err != nil {
+		return nil, err
+	}
+	if err := applyPodTemplate(&statefulset.Spec.Template, dcontext.PodTemplate); err != nil {
+		return nil, err
+	}
+	return statefulset, err
+}
+
+// DaskWorkerService generates the headless Service description that
+// gives the workers of a StatefulSet their stable network identities
+func DaskWorkerService(dcontext dtypes.DaskContext) (*corev1.Service, error) {
+
+	const workerService = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: dask-worker-{{ .Name }}
+  namespace: {{ .Namespace }}
+  labels:
+    app.kubernetes.io/name: dask-worker
+    app.kubernetes.io/instance: "{{ .Name }}"
+    app.kubernetes.io/managed-by: DaskController
+spec:
+  clusterIP: None
+  publishNotReadyAddresses: true
+  selector:
+    app.kubernetes.io/name: dask-worker
+    app.kubernetes.io/instance: "{{ .Name }}"
+  ports:
+  - name: worker
+    port: 8788
+    targetPort: 8788
+    protocol: TCP
+`
+	result, err := utils.ApplyTemplate(workerService, dcontext)
+	if err != nil {
+		log.Debugf("ApplyTemplate Error: %+v\n", err)
+		return nil, err
+	}
+	service := &corev1.Service{}
+	if err := json.Unmarshal([]byte(result), service); err != nil {
+		return nil, err
+	}
+	return service, err
+}
+
 // DaskWorkerNetworkPolicy generates the NetworkPolicy description for
 // the Dask Worker
 func DaskWorkerNetworkPolicy(dcontext dtypes.DaskContext) (*networkingv1.NetworkPolicy, error) {
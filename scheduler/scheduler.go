@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/appscode/go/log"
+)
+
+// Timeout for calls to the Dask Scheduler
+var Timeout = 5 * time.Second
+
+// RetireTimeout is how long to wait on the Scheduler retiring workers -
+// the Scheduler carries on moving their data after the request times out
+var RetireTimeout = 10 * time.Second
+
+// memoryHighWater is the fraction of worker memory in use above which the
+// cluster is doubled - the same rule used by distributed's adaptive_target
+const memoryHighWater = 0.6
+
+// Counts is the task summary served by the Scheduler on /json/counts.json
+type Counts struct {
+	Processing int `json:"processing"`
+	Waiting    int `json:"waiting"`
+	Memory     int `json:"memory"`
+	Unrunnable int `json:"unrunnable"`
+	Workers    int `json:"workers"`
+	Cores      int `json:"cores"`
+	Tasks      int `json:"tasks"`
+	Clients    int `json:"clients"`
+}
+
+// WorkerMetrics are the live metrics of a worker
+type WorkerMetrics struct {
+	Memory    int64 `json:"memory"`
+	Executing int   `json:"executing"`
+	Ready     int   `json:"ready"`
+}
+
+// Worker is a worker as described on /json/identity.json
+type Worker struct {
+	Name        interface{}   `json:"name"`
+	Host        string        `json:"host"`
+	NThreads    int           `json:"nthreads"`
+	NCores      int           `json:"ncores"`
+	MemoryLimit int64         `json:"memory_limit"`
+	Metrics     WorkerMetrics `json:"metrics"`
+}
+
+// Threads gives the worker thread count across distributed versions
+func (w Worker) Threads() int {
+	if w.NThreads > 0 {
+		return w.NThreads
+	}
+	return w.NCores
+}
+
+// Identity is the Scheduler description served on /json/identity.json
+type Identity struct {
+	Type    string            `json:"type"`
+	ID      string            `json:"id"`
+	Address string            `json:"address"`
+	Workers map[string]Worker `json:"workers"`
+}
+
+// Load is the snapshot of Scheduler load used for scaling decisions
+type Load struct {
+	Counts   Counts
+	Identity Identity
+}
+
+// getJSON fetches and decodes one of the Scheduler JSON endpoints
+func getJSON(url string, out interface{}) error {
+	client := http.Client{Timeout: Timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// GetLoad polls the Scheduler monitor (bokeh) endpoint at address
+// eg: http://dask-scheduler-app1.default:8787
+func GetLoad(address string) (*Load, error) {
+	load := Load{}
+	if err := getJSON(address+"/json/counts.json", &load.Counts); err != nil {
+		return nil, err
+	}
+	identity, err := GetIdentity(address)
+	if err != nil {
+		return nil, err
+	}
+	load.Identity = *identity
+	log.Debugf("Scheduler load at %s: %+v", address, load)
+	return &load, nil
+}
+
+// GetIdentity fetches the Scheduler description, including its workers
+func GetIdentity(address string) (*Identity, error) {
+	identity := Identity{}
+	if err := getJSON(address+"/json/identity.json", &identity); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// RetireWorkers asks the Scheduler to retire the workers at the given
+// worker addresses, moving the data they hold on to the remaining workers.
+// It reports false when the retirement is still going on at the timeout.
+// This needs the distributed.http.scheduler.api routes to be enabled.
+func RetireWorkers(address string, workers []string) (bool, error) {
+	body, err := json.Marshal(map[string]interface{}{"workers": workers})
+	if err != nil {
+		return false, err
+	}
+	client := http.Client{Timeout: RetireTimeout}
+	url := address + "/api/v1/retire_workers"
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			log.Debugf("Retiring workers at %s: %v", address, workers)
+			return false, nil
+		}
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("POST %s: %s", url, resp.Status)
+	}
+	log.Debugf("Retired workers at %s: %v", address, workers)
+	return true, nil
+}
+
+// Idle checks that the Scheduler has no tasks and no clients - the
+// Scheduler always holds its own fire-and-forget client
+func (l *Load) Idle() bool {
+	return l.Counts.Tasks == 0 && l.Counts.Clients <= 1
+}
+
+// Target works out the number of workers wanted for the current load,
+// estimated from the task backlog spread across the worker threads, and
+// doubled when worker memory runs high.
+func (l *Load) Target() int32 {
+	workers := len(l.Identity.Workers)
+	threads := 0
+	var used, limit int64
+	for _, w := range l.Identity.Workers {
+		threads += w.Threads()
+		used += w.Metrics.Memory
+		limit += w.MemoryLimit
+	}
+	perWorker := 1.0
+	if workers > 0 && threads > 0 {
+		perWorker = float64(threads) / float64(workers)
+	}
+
+	backlog := l.Counts.Waiting + l.Counts.Processing
+	cpu := int(math.Ceil(float64(backlog) / perWorker))
+	if cpu == 0 && l.Counts.Unrunnable > 0 {
+		cpu = 1
+	}
+
+	memory := 0
+	if limit > 0 && float64(used) > memoryHighWater*float64(limit) {
+		memory = 2 * workers
+	}
+
+	if memory > cpu {
+		return int32(memory)
+	}
+	return int32(cpu)
+}
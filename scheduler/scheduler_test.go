@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scheduler load", func() {
+
+	var stub *httptest.Server
+	var load *Load
+
+	BeforeEach(func() {
+		stub = httptest.NewServer(http.FileServer(http.Dir("testdata")))
+		var err error
+		load, err = GetLoad(stub.URL)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		stub.Close()
+	})
+
+	It("should read the counts and workers served by the Scheduler", func() {
+		Expect(load.Counts.Waiting).To(Equal(16))
+		Expect(load.Counts.Processing).To(Equal(4))
+		Expect(load.Counts.Clients).To(Equal(2))
+		Expect(load.Identity.Workers).To(HaveLen(2))
+		Expect(load.Identity.Workers["tcp://10.244.0.13:38213"].Threads()).To(Equal(2))
+		Expect(load.Idle()).To(BeFalse())
+	})
+
+	It("should spread the task backlog across the worker threads", func() {
+		// 20 tasks waiting or processing, at 2 threads a worker
+		Expect(load.Target()).To(Equal(int32(10)))
+	})
+
+	It("should double the workers when their memory runs high", func() {
+		load.Counts.Waiting, load.Counts.Processing = 0, 2
+		for address, worker := range load.Identity.Workers {
+			worker.Metrics.Memory = worker.MemoryLimit * 3 / 4
+			load.Identity.Workers[address] = worker
+		}
+		Expect(load.Target()).To(Equal(int32(4)))
+	})
+
+	It("should want a worker for unrunnable tasks when there are none", func() {
+		load.Counts.Waiting, load.Counts.Processing, load.Counts.Unrunnable = 0, 0, 3
+		load.Identity.Workers = nil
+		Expect(load.Target()).To(Equal(int32(1)))
+	})
+
+	It("should want no workers when the Scheduler is idle", func() {
+		load.Counts.Waiting, load.Counts.Processing, load.Counts.Tasks, load.Counts.Clients = 0, 0, 0, 1
+		Expect(load.Idle()).To(BeTrue())
+		Expect(load.Target()).To(Equal(int32(0)))
+	})
+})
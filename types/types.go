@@ -3,10 +3,15 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/appscode/go/log"
 	"github.com/go-logr/logr"
 	analyticsv1 "gitlab.com/piersharding/dask-operator/api/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // Image Default Container Image
@@ -15,20 +20,48 @@ var Image string
 // PullPolicy Default image pull policy
 var PullPolicy string
 
+// RestrictedUser is the user that Pods run as under the restricted profile
+var RestrictedUser int64 = 1000
+
+// RestrictedGroup is the group that Pods run as under the restricted profile
+var RestrictedGroup int64 = 100
+
 // DaskContext is the set of parameters to configures this instance
 type DaskContext struct {
 	JupyterIngress     string
 	SchedulerIngress   string
 	MonitorIngress     string
+	IngressV1          bool
+	IngressClassName   string
+	IngressAnnotations map[string]string
+	IngressTLS         []networkingv1.IngressTLS
+	Gateway            bool
+	GatewayAPIVersion  string
+	GatewayName        string
+	GatewayNamespace   string
+	GatewaySection     string
+	GatewayFrom        []networkingv1.NetworkPolicyPeer
+	DashboardHostname  string
+	DashboardURL       string
 	Daemon             bool
 	Jupyter            bool
 	DisablePolicies    bool
 	Namespace          string
 	Name               string
 	ServiceType        string
+	ServiceAnnotations map[string]string
+	SourceRanges       []string
+	NodePorts          map[string]int32
+	SchedulerService   *analyticsv1.DaskServiceSpec
+	NotebookService    *analyticsv1.DaskServiceSpec
 	Port               int
 	BokehPort          int
 	Replicas           int32
+	Suspended          bool
+	TLS                bool
+	TLSVersion         string
+	Hibernated         bool
+	HibernateNotebook  bool
 	Cluster            string
 	Script             string
 	ScriptType         string
@@ -45,14 +78,50 @@ type DaskContext struct {
 	Affinity           interface{}
 	Tolerations        interface{}
 	Resources          interface{}
+	PodTemplate        interface{}
+	PriorityClassName  string
+	Restricted         bool
+	PodSecurityContext interface{}
+	SecurityContext    interface{}
 	VolumeMounts       interface{}
 	Volumes            interface{}
 	Env                interface{}
 	JupyterImage       string
 	JupyterPassword    string
+	JupyterAuthType    string
+	JupyterAuthSecret  string
+	JupyterAuthKey     string
+	JupyterAuthHashed  bool
+	NotebookMode       string
+	NotebookBaseURL    string
 	Scheduler          interface{}
 	Worker             interface{}
 	Notebook           interface{}
+	WorkerGroups       []analyticsv1.DaskWorkerGroup
+	WorkerGroup        string
+	HeldReplicas       map[string]int32
+	WorkerStatefulSet  bool
+	WorkerStorageClass string
+	WorkerStorageSize  string
+	DaskResources      string
+	NThreads           int32
+	NWorkers           int32
+	MemoryFraction     string
+	CPUResource        string
+	MemoryResource     string
+	PDBV1              bool
+	SchedulerPDB       bool
+	WorkerPDB          bool
+	MinAvailable       *intstr.IntOrString
+	MaxUnavailable     *intstr.IntOrString
+	DaskConfig         map[string]interface{}
+	DaskConfigKey      string
+	DaskConfigFiles    map[string]interface{}
+	Command            interface{}
+	Args               interface{}
+	StartScript        interface{}
+	DaskVersion        string
+	DaskLines          interface{}
 }
 
 // SetConfig setup the configuration
@@ -64,13 +133,13 @@ func SetConfig(dask analyticsv1.Dask) DaskContext {
 		MonitorIngress:     dask.Spec.MonitorIngress,
 		Daemon:             dask.Spec.Daemon,
 		Jupyter:            dask.Spec.Jupyter,
-		DisablePolicies:    dask.Spec.DisablePolicies,
+		DisablePolicies:    dask.Spec.DisablePolicies != nil && *dask.Spec.DisablePolicies,
 		Namespace:          dask.Namespace,
 		Name:               dask.Name,
 		ServiceType:        "ClusterIP",
 		Port:               8786,
 		BokehPort:          8787,
-		Replicas:           dask.Spec.Replicas,
+		Replicas:           5,
 		Image:              dask.Spec.Image,
 		Script:             "/notebook.ipynb",
 		ScriptType:         "",
@@ -89,9 +158,20 @@ func SetConfig(dask analyticsv1.Dask) DaskContext {
 		Env:                dask.Spec.Env,
 		JupyterImage:       "jupyter/scipy-notebook:latest",
 		JupyterPassword:    dask.Spec.JupyterPassword,
-		Scheduler:          dask.Spec.Scheduler,
-		Worker:             dask.Spec.Worker,
-		Notebook:           dask.Spec.Notebook}
+		Scheduler:          (*analyticsv1.DaskDeploymentSpec)(nil),
+		Worker:             (*analyticsv1.DaskDeploymentSpec)(nil),
+		Notebook:           (*analyticsv1.DaskDeploymentSpec)(nil),
+		NotebookMode:       "classic",
+		NotebookBaseURL:    "/",
+		WorkerStorageSize:  "10Gi",
+		NWorkers:           1,
+		MemoryFraction:     "0.8",
+		DaskConfigKey:      "dask.yaml",
+		DaskVersion:        dask.Spec.DaskVersion,
+		Suspended:          dask.Spec.Suspend,
+		TLS:                dask.Spec.TLS != nil,
+		HibernateNotebook:  dask.Spec.HibernateNotebook,
+		WorkerGroups:       dask.Spec.WorkerGroups}
 
 	// if dask.Spec.Daemon != nil {
 	// 	context.Daemon = *dask.Spec.Daemon
@@ -101,78 +181,323 @@ func SetConfig(dask analyticsv1.Dask) DaskContext {
 	// 	context.Jupyter = *dask.Spec.Jupyter
 	// }
 
-	// default of 5 replicas for workers
-	if dask.Spec.Replicas == 0 {
-		context.Replicas = 5
+	// the Dask configuration of the cluster
+	context.DaskConfig = mergeDaskConfig(nil, dask.Spec.DaskConfig)
+
+	// the Scheduler and Notebook settings, with the base URL always ending in /
+	if dask.Spec.Scheduler != nil {
+		context.Scheduler = &dask.Spec.Scheduler.DaskDeploymentSpec
+		context.SchedulerService = dask.Spec.Scheduler.Service
+	}
+	if dask.Spec.Notebook != nil {
+		context.Notebook = &dask.Spec.Notebook.DaskDeploymentSpec
+		context.NotebookService = dask.Spec.Notebook.Service
+		if dask.Spec.Notebook.Mode != "" {
+			context.NotebookMode = dask.Spec.Notebook.Mode
+		}
+		if dask.Spec.Notebook.BaseURL != "" {
+			context.NotebookBaseURL = strings.TrimSuffix(dask.Spec.Notebook.BaseURL, "/") + "/"
+		}
+	}
+
+	// the workers, and the volumes they spill to when in a StatefulSet
+	if dask.Spec.Worker != nil {
+		context.Worker = &dask.Spec.Worker.DaskDeploymentSpec
+		context.WorkerStatefulSet = dask.Spec.Worker.Kind == "StatefulSet"
+		if storage := dask.Spec.Worker.Storage; storage != nil {
+			if storage.StorageClassName != nil {
+				context.WorkerStorageClass = *storage.StorageClassName
+			}
+			if storage.Size != nil {
+				context.WorkerStorageSize = storage.Size.String()
+			}
+		}
+	}
 
+	// default of 5 replicas for workers, unless they are in groups
+	if dask.Spec.Replicas != nil {
+		context.Replicas = *dask.Spec.Replicas
+	} else if len(dask.Spec.WorkerGroups) > 0 {
+		context.Replicas = 0
 	}
+
 	if context.MonitorIngress == "" {
 		context.MonitorIngress = "monitor.dask.local"
 	}
 
-	if context.JupyterPassword == "" {
-		context.JupyterPassword = "password"
+	// the image and pull policy of the operator, when not given by the Dask
+	// or its class
+	if context.Image == "" {
+		context.Image = Image
+	}
+	if context.PullPolicy == "" {
+		context.PullPolicy = PullPolicy
+	}
+
+	// the restricted Pod Security Standard runs everything as the same
+	// non-root user as the Jupyter images, with nothing to escalate to
+	if dask.Spec.SecurityProfile == "restricted" {
+		context.Restricted = true
+		context.PodSecurityContext = map[string]interface{}{
+			"runAsNonRoot":   true,
+			"runAsUser":      RestrictedUser,
+			"runAsGroup":     RestrictedGroup,
+			"fsGroup":        RestrictedGroup,
+			"seccompProfile": map[string]interface{}{"type": "RuntimeDefault"},
+		}
+		context.SecurityContext = map[string]interface{}{
+			"allowPrivilegeEscalation": false,
+			"readOnlyRootFilesystem":   true,
+			"capabilities":             map[string]interface{}{"drop": []string{"ALL"}},
+		}
+	}
+
+	// the Scheduler is protected by default once there is a disruption
+	// budget, but the workers only when there is a bound on them
+	if budget := dask.Spec.DisruptionBudget; budget != nil {
+		context.SchedulerPDB = budget.Scheduler == nil || *budget.Scheduler
+		context.WorkerPDB = budget.MinAvailable != nil || budget.MaxUnavailable != nil
+		context.MinAvailable = budget.MinAvailable
+		context.MaxUnavailable = budget.MaxUnavailable
+	}
+
+	// HTTPRoutes on a Gateway replace the Ingress
+	if dask.Spec.Gateway != nil {
+		context.Gateway = true
+		context.GatewayName = dask.Spec.Gateway.ParentRef.Name
+		context.GatewayNamespace = dask.Spec.Gateway.ParentRef.Namespace
+		if context.GatewayNamespace == "" {
+			context.GatewayNamespace = dask.Namespace
+		}
+		context.GatewaySection = dask.Spec.Gateway.ParentRef.SectionName
+		context.GatewayFrom = dask.Spec.Gateway.From
+		context.DashboardHostname = dask.Spec.MonitorIngress
+	}
+
+	// the Ingress keeps to the nginx settings, unless annotations are given
+	ingress := dask.Spec.Ingress
+	if ingress == nil {
+		ingress = &analyticsv1.DaskIngressSpec{}
+	}
+	if ingress.ClassName != nil {
+		context.IngressClassName = *ingress.ClassName
+	}
+	context.IngressTLS = ingress.TLS
+	context.IngressAnnotations = ingress.Annotations
+	if context.IngressAnnotations == nil {
+		context.IngressAnnotations = map[string]string{"nginx.ingress.kubernetes.io/x-forwarded-prefix": "true"}
+		if len(ingress.TLS) == 0 {
+			context.IngressAnnotations["nginx.ingress.kubernetes.io/ssl-redirect"] = "false"
+		}
+	}
+
+	// the dashboard as a browser sees it, when it is exposed
+	if context.Gateway && context.DashboardHostname != "" {
+		context.DashboardURL = fmt.Sprintf("http://%s/", context.DashboardHostname)
+	} else if context.SchedulerIngress != "" && !context.Gateway {
+		context.DashboardURL = fmt.Sprintf("%s://%s/", context.IngressScheme(context.MonitorIngress), context.MonitorIngress)
 	}
 
 	log.Debugf("context: %+v", context)
 	return context
 }
 
+// IngressScheme gives the URL scheme of an Ingress host
+func (context DaskContext) IngressScheme(host string) string {
+	for _, tls := range context.IngressTLS {
+		for _, h := range tls.Hosts {
+			if h == host {
+				return "https"
+			}
+		}
+	}
+	return "http"
+}
+
+// SetClassConfig setup the configuration over the defaults of the
+// DaskClusterClass of the Dask, which its own settings take precedence over
+func SetClassConfig(dask analyticsv1.Dask, class *analyticsv1.DaskClusterClass) DaskContext {
+	context := SetConfig(analyticsv1.ApplyClass(dask, class))
+	if class != nil && class.Spec.JupyterImage != "" {
+		context.JupyterImage = class.Spec.JupyterImage
+	}
+	return context
+}
+
 // ForNotebook - copy and arrange config values for Notebook
 func (context *DaskContext) ForNotebook() DaskContext {
-	out := new(DaskContext)
-	out = context
+	out := *context
 	out.applySpecifics(context.Notebook.(*analyticsv1.DaskDeploymentSpec))
-	return *out
+	out.applyDaskConfigKey(context.Notebook.(*analyticsv1.DaskDeploymentSpec), "dask-notebook.yaml")
+	out.applyService(context.NotebookService)
+	return out
 }
 
 // ForScheduler - copy and arrange config values for Scheduler
 func (context *DaskContext) ForScheduler() DaskContext {
-	out := new(DaskContext)
-	out = context
+	out := *context
 	out.applySpecifics(context.Scheduler.(*analyticsv1.DaskDeploymentSpec))
-	return *out
+	out.applyDaskConfigKey(context.Scheduler.(*analyticsv1.DaskDeploymentSpec), "dask-scheduler.yaml")
+	out.applyService(context.SchedulerService)
+	return out
 }
 
 // ForWorker - copy and arrange config values for Worker
 func (context *DaskContext) ForWorker() DaskContext {
-	out := new(DaskContext)
-	out = context
+	out := *context
 	out.applySpecifics(context.Worker.(*analyticsv1.DaskDeploymentSpec))
+	out.applyDaskConfigKey(context.Worker.(*analyticsv1.DaskDeploymentSpec), "dask-worker.yaml")
 	// if reflect.TypeOf(context.Worker) == reflect.TypeOf(&analyticsv1.DaskDeploymentSpec{}) {
 	// 	if context.Worker.(*analyticsv1.DaskDeploymentSpec) != nil {
-	return *out
+	return out
+}
+
+// ForWorkerGroup - copy and arrange config values for a group of Workers
+func (context *DaskContext) ForWorkerGroup(group analyticsv1.DaskWorkerGroup) DaskContext {
+	out := context.ForWorker()
+	out.overlaySpecifics(&group.DaskDeploymentSpec)
+	out.applyDaskConfigKey(&group.DaskDeploymentSpec, "dask-worker-"+group.Name+".yaml")
+	out.WorkerGroup = group.Name
+	out.Replicas = 1
+	if group.Replicas != nil {
+		out.Replicas = *group.Replicas
+	}
+	if context.Suspended || context.Hibernated {
+		out.Replicas = 0
+	}
+	if held, ok := context.HeldReplicas[group.Name]; ok {
+		out.Replicas = held
+	}
+	return out
+}
+
+// applyService - copy the settings of how a Service is exposed
+func (context *DaskContext) applyService(service *analyticsv1.DaskServiceSpec) {
+	if service != nil {
+		if service.Type != "" {
+			context.ServiceType = string(service.Type)
+		}
+		context.ServiceAnnotations = service.Annotations
+		context.SourceRanges = service.LoadBalancerSourceRanges
+		context.NodePorts = service.NodePorts
+	}
+}
+
+// applyDaskConfigKey - a component that has Dask configuration of its own
+// is given its own dask.yaml in the ConfigMap
+func (context *DaskContext) applyDaskConfigKey(specific *analyticsv1.DaskDeploymentSpec, key string) {
+	if specific != nil && specific.DaskConfig != nil {
+		context.DaskConfigKey = key
+	}
+}
+
+// mergeDaskConfig - merge the Dask configuration over the base key by key,
+// as Dask does with its own configuration files, without changing the base
+func mergeDaskConfig(base map[string]interface{}, config *runtime.RawExtension) map[string]interface{} {
+	if config == nil {
+		return base
+	}
+	var overlay map[string]interface{}
+	if err := json.Unmarshal(config.Raw, &overlay); err != nil {
+		return base
+	}
+	return mergeMaps(base, overlay)
+}
+
+// mergeMaps - recursively merge overlay over base into a new map
+func mergeMaps(base, overlay map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range overlay {
+		if o, ok := v.(map[string]interface{}); ok {
+			if b, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeMaps(b, o)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
 }
 
 // applySpecifics - copy and arrange config values for deployment class
 func (context *DaskContext) applySpecifics(specific *analyticsv1.DaskDeploymentSpec) {
 
 	if specific != nil {
-		byt, _ := json.Marshal(specific)
-		json.Unmarshal(byt, context)
-		if specific.Volumes == nil {
-			context.Volumes = nil
-		}
-		if specific.VolumeMounts == nil {
-			context.VolumeMounts = nil
-		}
-		if specific.Env == nil {
-			context.Env = nil
-		}
-		if specific.PullSecrets == nil {
-			context.PullSecrets = nil
-		}
-		if specific.NodeSelector == nil {
-			context.NodeSelector = nil
-		}
-		if specific.Affinity == nil {
-			context.Affinity = nil
-		}
-		if specific.Tolerations == nil {
-			context.Tolerations = nil
-		}
-		if specific.Resources == nil {
-			context.Resources = nil
+		// the specific settings replace the general ones wholesale
+		context.Volumes = nil
+		context.VolumeMounts = nil
+		context.Env = nil
+		context.PullSecrets = nil
+		context.NodeSelector = nil
+		context.Affinity = nil
+		context.Tolerations = nil
+		context.Resources = nil
+		context.PodTemplate = nil
+		context.PriorityClassName = ""
+		context.Command = nil
+		context.Args = nil
+		context.StartScript = nil
+		context.overlaySpecifics(specific)
+	}
+}
+
+// overlaySpecifics - replace only the config values that are set in specific.
+// Values are washed through JSON so that the templates see the generic form,
+// and nothing is shared with the resource spec.
+func (context *DaskContext) overlaySpecifics(specific *analyticsv1.DaskDeploymentSpec) {
+	fields := map[string]interface{}{}
+	byt, _ := json.Marshal(specific)
+	json.Unmarshal(byt, &fields)
+	for k, v := range fields {
+		switch k {
+		case "volumes":
+			context.Volumes = v
+		case "volumeMounts":
+			context.VolumeMounts = v
+		case "env":
+			context.Env = v
+		case "imagePullSecrets":
+			context.PullSecrets = v
+		case "nodeSelector":
+			context.NodeSelector = v
+		case "affinity":
+			context.Affinity = v
+		case "tolerations":
+			context.Tolerations = v
+		case "resources":
+			context.Resources = v
+		case "podTemplate":
+			context.PodTemplate = v
+		case "priorityClassName":
+			context.PriorityClassName, _ = v.(string)
+		case "daskResources":
+			var res []string
+			for name, value := range v.(map[string]interface{}) {
+				res = append(res, fmt.Sprintf("%s=%v", name, value))
+			}
+			sort.Strings(res)
+			context.DaskResources = strings.Join(res, ",")
+		case "nthreads":
+			context.NThreads = int32(v.(float64))
+		case "nworkers":
+			context.NWorkers = int32(v.(float64))
+		case "memoryFraction":
+			context.MemoryFraction, _ = v.(string)
+		case "command":
+			context.Command = v
+			context.StartScript = nil
+		case "args":
+			context.Args = v
+		case "startScript":
+			context.StartScript = v
+			context.Command = nil
+		case "daskConfig":
+			if config, ok := v.(map[string]interface{}); ok {
+				context.DaskConfig = mergeMaps(context.DaskConfig, config)
+			}
 		}
 	}
 }
@@ -193,6 +518,20 @@ func (context *DaskContext) SetJobConfig(daskjob *analyticsv1.DaskJob) {
 		context.Cluster = daskjob.Spec.Cluster
 		context.Script = daskjob.Spec.Script
 		context.Report = daskjob.Spec.Report
+		// the report volume is shared through its group rather than opened
+		// up to everyone
+		if context.Report && context.PodSecurityContext == nil {
+			context.PodSecurityContext = map[string]interface{}{"fsGroup": RestrictedGroup}
+		}
+		context.PriorityClassName = daskjob.Spec.PriorityClassName
+		context.DaskConfig = mergeDaskConfig(context.DaskConfig, daskjob.Spec.DaskConfig)
+		context.PodTemplate = nil
+		if daskjob.Spec.PodTemplate != nil {
+			var podTemplate map[string]interface{}
+			if err := json.Unmarshal(daskjob.Spec.PodTemplate.Raw, &podTemplate); err == nil {
+				context.PodTemplate = podTemplate
+			}
+		}
 	}
 }
 
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DiffPaths lists the paths at which the desired state differs from the
+// observed state of a resource.  Only the fields set in desired are
+// checked, so values filled in by API server defaulting are not drift.
+func DiffPaths(desired, observed interface{}, path string) []string {
+	var paths []string
+	switch want := desired.(type) {
+	case nil:
+		// nothing asked for, so nothing to compare
+	case map[string]interface{}:
+		have, ok := observed.(map[string]interface{})
+		if !ok {
+			if len(want) > 0 {
+				paths = append(paths, path)
+			}
+			return paths
+		}
+		for _, k := range sortedKeys(want) {
+			paths = append(paths, DiffPaths(want[k], have[k], joinPath(path, k))...)
+		}
+	case []interface{}:
+		have, ok := observed.([]interface{})
+		if !ok || len(want) != len(have) {
+			if ok || len(want) > 0 {
+				paths = append(paths, path)
+			}
+			return paths
+		}
+		for i := range want {
+			paths = append(paths, DiffPaths(want[i], have[i], fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	default:
+		if !reflect.DeepEqual(want, observed) && !sameQuantity(path, want, observed) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// sameQuantity checks whether two resource quantities have the same value -
+// the API server canonicalises them, so "0.5" comes back as "500m"
+func sameQuantity(path string, desired, observed interface{}) bool {
+	if !isQuantityPath(path) {
+		return false
+	}
+	want, err := resource.ParseQuantity(fmt.Sprint(desired))
+	if err != nil {
+		return false
+	}
+	have, err := resource.ParseQuantity(fmt.Sprint(observed))
+	if err != nil {
+		return false
+	}
+	return want.Cmp(have) == 0
+}
+
+// isQuantityPath picks out the fields that hold resource quantities - the
+// requests, limits and capacity of containers and volume claims, and the
+// size of emptyDir volumes
+func isQuantityPath(path string) bool {
+	for _, parent := range []string{"requests", "limits", "capacity", "hard", "overhead"} {
+		if strings.Contains("."+path, "."+parent+".") {
+			return true
+		}
+	}
+	return strings.HasSuffix(path, "sizeLimit")
+}
+
+// RemovedPaths lists the paths that were set in the last applied state,
+// have since been dropped from the desired state, and are still present
+// in the observed state.
+func RemovedPaths(lastApplied, desired, observed interface{}, path string) []string {
+	var paths []string
+	last, ok := lastApplied.(map[string]interface{})
+	if !ok {
+		return paths
+	}
+	want, _ := desired.(map[string]interface{})
+	have, ok := observed.(map[string]interface{})
+	if !ok {
+		return paths
+	}
+	for _, k := range sortedKeys(last) {
+		if _, found := have[k]; !found {
+			continue
+		}
+		if _, found := want[k]; !found {
+			paths = append(paths, joinPath(path, k))
+			continue
+		}
+		paths = append(paths, RemovedPaths(last[k], want[k], have[k], joinPath(path, k))...)
+	}
+	return paths
+}
+
+// MergeDesired overlays the desired state on the observed state of a
+// resource. Maps are merged recursively so that fields owned by the API
+// server survive, everything else is replaced, and fields that were in the
+// last applied state but are no longer desired are dropped.
+func MergeDesired(observed, lastApplied, desired interface{}) interface{} {
+	want, ok := desired.(map[string]interface{})
+	if !ok {
+		return desired
+	}
+	have, ok := observed.(map[string]interface{})
+	if !ok {
+		return desired
+	}
+	last, _ := lastApplied.(map[string]interface{})
+	merged := map[string]interface{}{}
+	for k, v := range have {
+		if _, found := want[k]; found {
+			continue
+		}
+		if _, found := last[k]; found {
+			// we set it last time, and it is no longer wanted
+			continue
+		}
+		merged[k] = v
+	}
+	for k, v := range want {
+		merged[k] = MergeDesired(have[k], last[k], v)
+	}
+	return merged
+}
+
+// sortedKeys gives a stable order for reporting paths
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}